@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -18,13 +19,26 @@ import (
 	"a360-platform/backend/internal/auth"
 	"a360-platform/backend/internal/handlers"
 	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/pipeline"
 	"a360-platform/backend/internal/s3"
+	"a360-platform/backend/internal/scheduler"
 )
 
 func main() {
 	// Load .env
 	_ = godotenv.Load()
 
+	// Configured output formats must be encodable, scenes would fail otherwise
+	for _, envVar := range []string{"FACE_FORMATS", "THUMBNAIL_FORMATS"} {
+		profiles, err := pipeline.ParseProfiles(os.Getenv(envVar))
+		if err == nil {
+			err = pipeline.CheckEncoders(profiles)
+		}
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", envVar, err)
+		}
+	}
+
 	// DB Connection with Retries
 	dsn := "host=" + os.Getenv("DB_HOST") +
 		" user=" + os.Getenv("DB_USER") +
@@ -49,11 +63,24 @@ func main() {
 	}
 
 	// Auto-migrate
-	db.AutoMigrate(&models.User{}, &models.Project{}, &models.Scene{}, &models.Hotspot{}, &models.Invitation{}, &models.RegistrationCode{}, &models.AuditLog{})
+	db.AutoMigrate(&models.User{}, &models.Project{}, &models.Scene{}, &models.Hotspot{}, &models.Invitation{}, &models.RegistrationCode{}, &models.AuditLog{}, &models.Branding{}, &models.BlurRegion{}, &models.Session{}, &models.MembershipPolicy{}, &models.Reminder{}, &models.ExtensionRequest{}, &models.Cohort{}, &models.Comment{}, &models.Organization{}, &models.APIToken{}, &models.RecoveryCode{})
 
 	// SELF-HEALING: Populate ValidFrom and ExpiresAt for existing users
 	db.Model(&models.User{}).Where("valid_from = ? OR valid_from IS NULL", time.Time{}).Update("valid_from", gorm.Expr("created_at"))
 	db.Model(&models.User{}).Where("expires_at = ? OR expires_at IS NULL", time.Time{}).Update("expires_at", gorm.Expr("created_at + interval '30 days'"))
+	db.Model(&models.User{}).Where("locks_at = ? OR locks_at IS NULL", time.Time{}).Update("locks_at", gorm.Expr("expires_at + interval '60 days'"))
+
+	// SELF-HEALING: Give admins from before roles existed the admin role, and make
+	// the longest-standing admin super-admin if there is none
+	db.Model(&models.User{}).Where("is_admin = ? AND role NOT IN ?", true, []string{auth.RoleAdmin, auth.RoleSuperAdmin}).Update("role", auth.RoleAdmin)
+	var superAdmins int64
+	db.Model(&models.User{}).Where("role = ? AND is_admin = ?", auth.RoleSuperAdmin, true).Count(&superAdmins)
+	if superAdmins == 0 {
+		var first models.User
+		if db.Where("is_admin = ?", true).Order("created_at").First(&first).Error == nil {
+			db.Model(&first).Update("role", auth.RoleSuperAdmin)
+		}
+	}
 
 	// Update existing users with 0 or NULL project limit/quota to defaults
 	db.Model(&models.User{}).Where("project_limit IS NULL OR project_limit = 0").Update("project_limit", 3)
@@ -72,6 +99,11 @@ func main() {
 	db.Model(&models.RegistrationCode{}).Where("valid_from = ? OR valid_from IS NULL", time.Time{}).Update("valid_from", gorm.Expr("created_at"))
 	db.Model(&models.RegistrationCode{}).Where("expires_at = ? OR expires_at IS NULL", time.Time{}).Update("expires_at", gorm.Expr("created_at + interval '12 months'"))
 
+	// Lifecycle reminder emails, unless cmd/scheduler runs them separately
+	if os.Getenv("SCHEDULER_ENABLED") == "true" {
+		go scheduler.Start(context.Background(), db, scheduler.ConfigFromEnv())
+	}
+
 	app := fiber.New(fiber.Config{
 		BodyLimit: 1024 * 1024 * 1024, // 1GB for large pano/media uploads
 	})
@@ -88,7 +120,7 @@ func main() {
 	r2Service, _ := s3.NewR2Service()
 	authHandler := handlers.AuthHandler{DB: db}
 	projectHandler := handlers.ProjectHandler{DB: db, R2: r2Service}
-	adminHandler := handlers.AdminHandler{DB: db}
+	adminHandler := handlers.AdminHandler{DB: db, R2: r2Service}
 
 	api := app.Group("/api")
 
@@ -109,40 +141,107 @@ func main() {
 	authGroup := api.Group("/auth")
 	authGroup.Post("/register", authHandler.Register)
 	authGroup.Post("/login", loginLimiter, authHandler.Login)
+	authGroup.Post("/login/2fa", loginLimiter, authHandler.LoginTwoFactor)
 	authGroup.Post("/forgot-password", authHandler.ForgotPassword)
 	authGroup.Post("/reset-password", authHandler.ResetPassword)
 	authGroup.Get("/invitation-details", authHandler.GetInvitationDetails)
-	authGroup.Get("/me", auth.JWTMiddleware(), authHandler.GetProfile)
+	authGroup.Get("/me", auth.Authenticate(db), authHandler.GetProfile)
+	authGroup.Post("/refresh", authHandler.Refresh)
+	authGroup.Post("/logout", auth.Authenticate(db), auth.RequireSession(), authHandler.Logout)
+	authGroup.Get("/sessions", auth.Authenticate(db), auth.RequireSession(), authHandler.ListSessions)
+	authGroup.Delete("/sessions/:id", auth.Authenticate(db), auth.RequireSession(), authHandler.RevokeSession)
+	authGroup.Get("/tokens", auth.Authenticate(db), auth.RequireSession(), authHandler.ListAPITokens)
+	authGroup.Post("/tokens", auth.Authenticate(db), auth.RequireSession(), authHandler.CreateAPIToken)
+	authGroup.Delete("/tokens/:id", auth.Authenticate(db), auth.RequireSession(), authHandler.RevokeAPIToken)
+	authGroup.Get("/2fa", auth.Authenticate(db), auth.RequireSession(), authHandler.GetTwoFactor)
+	authGroup.Post("/2fa/setup", auth.Authenticate(db), auth.RequireSession(), authHandler.SetupTwoFactor)
+	authGroup.Post("/2fa/enable", auth.Authenticate(db), auth.RequireSession(), authHandler.EnableTwoFactor)
+	authGroup.Post("/2fa/disable", auth.Authenticate(db), auth.RequireSession(), authHandler.DisableTwoFactor)
+	authGroup.Post("/2fa/recovery-codes", auth.Authenticate(db), auth.RequireSession(), authHandler.RegenerateRecoveryCodes)
+	authGroup.Get("/extensions", auth.Authenticate(db), authHandler.ListExtensionRequests)
+	authGroup.Post("/extensions", auth.Authenticate(db), auth.RequireSession(), authHandler.RequestExtension)
 
 	// Admin routes (Protected)
-	adminGroup := api.Group("/admin", auth.JWTMiddleware(), auth.AdminMiddleware(db))
+	// Org-admins pass AdminMiddleware with a console scoped to their organization;
+	// platform-wide settings stay with platform admins
+	adminGroup := api.Group("/admin", auth.Authenticate(db), auth.RequireScope(auth.ScopeAdmin), auth.AdminMiddleware(db), auth.RequireTwoFactor())
+	platform := auth.RequirePermission(auth.PermManageUsers)
 	adminGroup.Post("/invite", adminHandler.CreateInvitation)
 	adminGroup.Get("/users", adminHandler.ListUsers)
-	adminGroup.Patch("/users/:id/toggle-admin", adminHandler.ToggleAdmin)
+	adminGroup.Patch("/users/:id/toggle-admin", auth.RequirePermission(auth.PermManageRoles), adminHandler.ToggleAdmin)
+	adminGroup.Patch("/users/:id/role", auth.RequirePermission(auth.PermManageRoles), adminHandler.SetUserRole)
 	adminGroup.Patch("/users/:id/toggle-active", adminHandler.ToggleActive)
 	adminGroup.Patch("/users/:id", adminHandler.UpdateUser)
 	adminGroup.Delete("/users/:id", adminHandler.DeleteUser)
-	adminGroup.Get("/audit-logs", adminHandler.GetAuditLogs)
+	adminGroup.Get("/users/:id/sessions", adminHandler.ListUserSessions)
+	adminGroup.Delete("/users/:id/sessions", adminHandler.RevokeUserSessions)
+	adminGroup.Delete("/users/:id/2fa", adminHandler.ResetUserTwoFactor)
+	adminGroup.Get("/audit-logs", platform, adminHandler.GetAuditLogs)
+	adminGroup.Get("/cohorts", adminHandler.ListCohorts)
+	adminGroup.Post("/cohorts", adminHandler.CreateCohort)
+	adminGroup.Get("/cohorts/:id", adminHandler.GetCohort)
+	adminGroup.Put("/cohorts/:id", adminHandler.UpdateCohort)
+	adminGroup.Delete("/cohorts/:id", adminHandler.DeleteCohort)
+	adminGroup.Get("/cohorts/:id/members", adminHandler.ListCohortMembers)
+	adminGroup.Post("/cohorts/:id/members", adminHandler.AddCohortMembers)
+	adminGroup.Delete("/cohorts/:id/members/:userID", adminHandler.RemoveCohortMember)
+	adminGroup.Get("/cohorts/:id/projects", adminHandler.ListCohortProjects)
+	adminGroup.Post("/cohorts/:id/extend", adminHandler.ExtendCohort)
+	adminGroup.Patch("/cohorts/:id/toggle-active", adminHandler.ToggleCohortActive)
+	adminGroup.Get("/extensions", adminHandler.ListExtensionRequests)
+	adminGroup.Post("/extensions/:id/approve", adminHandler.ApproveExtensionRequest)
+	adminGroup.Post("/extensions/:id/deny", adminHandler.DenyExtensionRequest)
+	adminGroup.Get("/organizations", adminHandler.ListOrganizations)
+	adminGroup.Post("/organizations", platform, adminHandler.CreateOrganization)
+	adminGroup.Put("/organizations/:id", platform, adminHandler.UpdateOrganization)
+	adminGroup.Delete("/organizations/:id", platform, adminHandler.DeleteOrganization)
+	adminGroup.Get("/policies", adminHandler.ListPolicies)
+	adminGroup.Post("/policies", platform, adminHandler.CreatePolicy)
+	adminGroup.Put("/policies/:id", platform, adminHandler.UpdatePolicy)
+	adminGroup.Delete("/policies/:id", platform, adminHandler.DeletePolicy)
 	adminGroup.Post("/reg-codes", adminHandler.CreateRegistrationCode)
 	adminGroup.Get("/reg-codes", adminHandler.ListRegistrationCodes)
 	adminGroup.Delete("/reg-codes/:id", adminHandler.DeleteRegistrationCode)
 	adminGroup.Patch("/reg-codes/:id/toggle", adminHandler.ToggleRegistrationCode)
 	adminGroup.Get("/stats", adminHandler.GetStats)
-	adminGroup.Post("/recalculate-storage", adminHandler.RecalculateStorage)
+	adminGroup.Post("/recalculate-storage", platform, adminHandler.RecalculateStorage)
 	adminGroup.Get("/invitations", adminHandler.ListInvitations)
 	adminGroup.Delete("/invitations/:id", adminHandler.DeleteInvitation)
+	adminGroup.Get("/branding", adminHandler.GetDefaultBranding)
+	adminGroup.Put("/branding", adminHandler.UpdateDefaultBranding)
 
 	// Protected routes
-	projectGroup := api.Group("/projects", auth.JWTMiddleware())
-	projectGroup.Post("/upload", projectHandler.UploadPano)
+	projectGroup := api.Group("/projects", auth.Authenticate(db), auth.RequireProjectScope(), auth.RequireTwoFactor(), auth.RequireView())
+	projectGroup.Post("/upload", auth.RequireEdit(), projectHandler.UploadPano)
+	projectGroup.Post("/import", auth.RequireEdit(), projectHandler.ImportProject)
 	projectGroup.Get("/", projectHandler.GetProjects)
-	projectGroup.Get("/:id", projectHandler.GetProject)
-	projectGroup.Put("/:id", projectHandler.UpdateProject)
-	projectGroup.Delete("/:id", projectHandler.DeleteProject)
-	projectGroup.Post("/:id/hotspots", projectHandler.SaveProjectHotspots)
-	projectGroup.Post("/scenes/:sceneID/hotspots", projectHandler.SaveHotspots)
-	projectGroup.Post("/media", projectHandler.UploadMedia)
-	projectGroup.Put("/scenes/:sceneID", projectHandler.UpdateScene)
+	projectGroup.Post("/media", auth.RequireEdit(), projectHandler.UploadMedia)
+
+	// Project and scene routes check access on the addressed project
+	view := projectHandler.ProjectAccess(handlers.AccessView)
+	review := projectHandler.ProjectAccess(handlers.AccessReview)
+	comment := projectHandler.ProjectAccess(handlers.AccessComment)
+	edit := projectHandler.ProjectAccess(handlers.AccessEdit)
+	unlock := projectHandler.ProjectAccess(handlers.AccessUnlock)
+	projectGroup.Get("/:id", view, projectHandler.GetProject)
+	projectGroup.Put("/:id", edit, projectHandler.UpdateProject)
+	projectGroup.Delete("/:id", edit, projectHandler.DeleteProject)
+	projectGroup.Post("/:id/hotspots", edit, projectHandler.SaveProjectHotspots)
+	projectGroup.Get("/:id/branding", review, projectHandler.GetProjectBranding)
+	projectGroup.Put("/:id/branding", edit, projectHandler.UpdateProjectBranding)
+	projectGroup.Delete("/:id/branding", edit, projectHandler.DeleteProjectBranding)
+	projectGroup.Get("/:id/comments", review, projectHandler.ListComments)
+	projectGroup.Post("/:id/comments", comment, projectHandler.CreateComment)
+	projectGroup.Delete("/:id/comments/:commentID", comment, projectHandler.DeleteComment)
+	projectGroup.Post("/:id/unlock", unlock, projectHandler.UnlockProject)
+	projectGroup.Delete("/:id/unlock", unlock, projectHandler.LockProject)
+	projectGroup.Post("/scenes/:sceneID/hotspots", edit, projectHandler.SaveHotspots)
+	projectGroup.Put("/scenes/:sceneID", edit, projectHandler.UpdateScene)
+	projectGroup.Put("/scenes/:sceneID/orientation", edit, projectHandler.UpdateSceneOrientation)
+	projectGroup.Put("/scenes/:sceneID/thumbnail", edit, projectHandler.UpdateSceneThumbnail)
+	projectGroup.Put("/scenes/:sceneID/tone-mapping", edit, projectHandler.UpdateSceneToneMapping)
+	projectGroup.Get("/scenes/:sceneID/blur-regions", review, projectHandler.GetBlurRegions)
+	projectGroup.Put("/scenes/:sceneID/blur-regions", edit, projectHandler.SaveBlurRegions)
 
 	// Public access route for tours
 	api.Get("/magic/:magicCode", projectHandler.GetProjectByMagicCode)
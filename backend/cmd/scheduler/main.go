@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/scheduler"
+)
+
+// Standalone lifecycle reminder scheduler, for deployments that run it apart
+// from the API (leave SCHEDULER_ENABLED unset on the API then).
+func main() {
+	once := flag.Bool("once", false, "send the reminders that are due and exit (for cron)")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	dsn := "host=" + os.Getenv("DB_HOST") +
+		" user=" + os.Getenv("DB_USER") +
+		" password=" + os.Getenv("DB_PASSWORD") +
+		" dbname=" + os.Getenv("DB_NAME") +
+		" port=" + os.Getenv("DB_PORT") +
+		" sslmode=disable TimeZone=Asia/Bangkok"
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	// It may start before the API ever ran against this database, so bring every
+	// table it reads or writes up to date (User references policies, cohorts and organizations)
+	if err := db.AutoMigrate(&models.Organization{}, &models.MembershipPolicy{}, &models.Cohort{}, &models.User{}, &models.Reminder{}, &models.AuditLog{}); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	cfg := scheduler.ConfigFromEnv()
+	if *once {
+		log.Printf("Sent %d reminders", scheduler.RunOnce(db, cfg, time.Now()))
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	scheduler.Start(ctx, db, cfg)
+}
@@ -0,0 +1,286 @@
+// Command slice runs the panorama pipeline over a folder of images without the server,
+// writing the same per-scene layout plus a bundle.json that POST /api/projects/import ingests.
+//
+//	go run ./cmd/slice -in ./shoot -out ./shoot-sliced -zip shoot.zip
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"a360-platform/backend/internal/pipeline"
+)
+
+func main() {
+	in := flag.String("in", "", "folder of equirectangular panoramas (.jpg, .jpeg, .png)")
+	out := flag.String("out", "", "output folder, one directory per scene plus "+pipeline.BundleFile)
+	zipPath := flag.String("zip", "", "also pack the output into this zip for upload")
+	workers := flag.Int("workers", max(1, runtime.NumCPU()/2), "panoramas sliced in parallel")
+	layout := flag.String("layout", pipeline.LayoutEquirect, "input layout: equirect, or cubemap for cross/strip images")
+	stereo := flag.String("stereo", pipeline.StereoAuto, "stereo layout: auto, mono, top-bottom, side-by-side")
+	projection := flag.String("projection", "", "equirectangular (default), cylindrical or dual_fisheye")
+	fisheyeFov := flag.Float64("fisheye-fov", 0, "lens field of view for dual_fisheye input, 0 = 190")
+	hFov := flag.Float64("h-fov", 0, "horizontal field of view in degrees, 0 = from GPano tags")
+	vFov := flag.Float64("v-fov", 0, "vertical field of view in degrees, 0 = from GPano tags or aspect")
+	fill := flag.String("fill", "", "#rrggbb for uncovered parts of partial panoramas")
+	autoLevel := flag.Bool("auto-level", true, "level the horizon from GPano pose tags")
+	strip := flag.Bool("strip-metadata", true, "remove GPS and identifying EXIF/XMP from originals")
+	keepLocation := flag.Bool("keep-location", false, "keep GPS when stripping metadata")
+	faceFormats := flag.String("face-formats", os.Getenv("FACE_FORMATS"), "face encodings, e.g. jpeg:85:progressive,webp:80")
+	thumbFormats := flag.String("thumbnail-formats", os.Getenv("THUMBNAIL_FORMATS"), "thumbnail encodings")
+	flag.Parse()
+
+	if *in == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *layout != pipeline.LayoutEquirect && *layout != pipeline.LayoutCubemap {
+		log.Fatalf("Invalid layout %q", *layout)
+	}
+	if !pipeline.IsStereoMode(*stereo) {
+		log.Fatalf("Invalid stereo mode %q", *stereo)
+	}
+	if *fill != "" {
+		if _, err := pipeline.ParseHexColor(*fill); err != nil {
+			log.Fatalf("Invalid fill color: %v", err)
+		}
+	}
+	faceProfiles, err := pipeline.ParseProfiles(*faceFormats)
+	if err != nil {
+		log.Fatalf("Invalid face formats: %v", err)
+	}
+	thumbProfiles, err := pipeline.ParseProfiles(*thumbFormats)
+	if err != nil {
+		log.Fatalf("Invalid thumbnail formats: %v", err)
+	}
+	if err := pipeline.CheckEncoders(append(faceProfiles, thumbProfiles...)); err != nil {
+		log.Fatalf("Cannot encode the requested formats: %v", err)
+	}
+
+	opts := pipeline.Options{
+		Stereo:            *stereo,
+		Projection:        *projection,
+		HFov:              *hFov,
+		VFov:              *vFov,
+		Fill:              *fill,
+		AutoLevel:         *autoLevel,
+		StripMetadata:     *strip,
+		KeepLocation:      *keepLocation,
+		FaceProfiles:      faceProfiles,
+		ThumbnailProfiles: thumbProfiles,
+	}
+	if *fisheyeFov > 0 {
+		opts.Fisheye = &pipeline.Fisheye{FOV: *fisheyeFov}
+	}
+
+	inputs, err := listPanoramas(*in)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *in, err)
+	}
+	if len(inputs) == 0 {
+		log.Fatalf("No panoramas found in %s", *in)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatalf("Failed to create %s: %v", *out, err)
+	}
+
+	bundle := pipeline.Bundle{
+		Version:   pipeline.BundleVersion,
+		CreatedAt: time.Now(),
+		Scenes:    make([]pipeline.BundleScene, len(inputs)),
+	}
+	dirs := sceneDirs(inputs)
+
+	log.Printf("Slicing %d panoramas with %d workers...", len(inputs), *workers)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				bundle.Scenes[i] = sliceOne(inputs[i], filepath.Join(*out, dirs[i]), *layout, opts)
+				bundle.Scenes[i].Dir = dirs[i]
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, s := range bundle.Scenes {
+		if s.Error != "" {
+			failed++
+			log.Printf("FAILED %s: %s", s.Source, s.Error)
+		}
+	}
+
+	if err := bundle.Write(filepath.Join(*out, pipeline.BundleFile)); err != nil {
+		log.Fatalf("Failed to write %s: %v", pipeline.BundleFile, err)
+	}
+	if *zipPath != "" {
+		if err := zipDir(*out, *zipPath); err != nil {
+			log.Fatalf("Failed to write %s: %v", *zipPath, err)
+		}
+		log.Printf("Packed %s", *zipPath)
+	}
+
+	log.Printf("Done: %d sliced, %d failed.", len(inputs)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// sliceOne copies input into sceneDir as original.jpg (assembling cube layouts first,
+// HDR input keeps its format) and slices it there, like the server does
+func sliceOne(input, sceneDir, layout string, opts pipeline.Options) pipeline.BundleScene {
+	name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	scene := pipeline.BundleScene{Name: name, Source: filepath.Base(input)}
+
+	start := time.Now()
+	originalFile := "original.jpg"
+	if layout == pipeline.LayoutEquirect && pipeline.IsHDRFile(input) {
+		originalFile = "original" + strings.ToLower(filepath.Ext(input))
+	}
+	original := filepath.Join(sceneDir, originalFile)
+	if err := os.MkdirAll(sceneDir, 0755); err != nil {
+		scene.Error = err.Error()
+		return scene
+	}
+	copyOriginal := copyFile
+	if layout == pipeline.LayoutCubemap {
+		copyOriginal = func(src, dst string) error {
+			return pipeline.AssembleEquirect([]string{src}, layout, dst)
+		}
+	}
+	if err := copyOriginal(input, original); err != nil {
+		scene.Error = err.Error()
+		return scene
+	}
+
+	res, err := pipeline.SlicePanoWithOptions(original, filepath.Join(sceneDir, "cubemap"), opts)
+	if err != nil {
+		os.RemoveAll(sceneDir)
+		scene.Error = err.Error()
+		return scene
+	}
+
+	result := pipeline.NewBundleScene(res, sceneDir, opts)
+	result.Name, result.Source = scene.Name, scene.Source
+	if originalFile != "original.jpg" {
+		result.Original = originalFile
+	}
+	log.Printf("Sliced %s in %s", scene.Source, time.Since(start).Round(time.Millisecond))
+	return result
+}
+
+func listPanoramas(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".jpg", ".jpeg", ".png", ".hdr", ".exr":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// sceneDirs derives a unique directory name per input from its file name
+func sceneDirs(inputs []string) []string {
+	seen := map[string]int{}
+	dirs := make([]string, len(inputs))
+	for i, input := range inputs {
+		base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		dir := strings.Trim(unsafeChars.ReplaceAllString(strings.ToLower(base), "-"), "-")
+		if dir == "" {
+			dir = "scene"
+		}
+		seen[dir]++
+		if n := seen[dir]; n > 1 {
+			dir = fmt.Sprintf("%s-%d", dir, n)
+		}
+		dirs[i] = dir
+	}
+	return dirs
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// zipDir packs every file below dir into zipPath with slash separated relative names
+func zipDir(dir, zipPath string) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		// Images are already compressed, only the manifest benefits from deflate
+		method := zip.Store
+		if filepath.Ext(path) == ".json" {
+			method = zip.Deflate
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: filepath.ToSlash(rel), Method: method, Modified: info.ModTime()})
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/policy"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CurrentUser returns the user loaded by Authenticate
+func CurrentUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals("user").(*models.User)
+	return user
+}
+
+// OrgScope returns the organization an org-admin's console is confined to,
+// nil for platform admins
+func OrgScope(c *fiber.Ctx) *uint {
+	org, _ := c.Locals("org_scope").(*uint)
+	return org
+}
+
+// CurrentPhase returns the caller's membership phase as of this request
+func CurrentPhase(c *fiber.Ctx) string {
+	phase, _ := c.Locals("phase").(string)
+	return phase
+}
+
+// CanEdit reports whether the caller may create or change content
+func CanEdit(c *fiber.Ctx) bool {
+	return CurrentPhase(c) == policy.PhaseCreative
+}
+
+// CanView reports whether the caller may see their own content
+func CanView(c *fiber.Ctx) bool {
+	phase := CurrentPhase(c)
+	return phase == policy.PhaseCreative || phase == policy.PhaseViewOnly
+}
+
+// RequireEdit guards routes that create or change content
+func RequireEdit() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !CanEdit(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Creative Phase expired. Only View-Only access is allowed. Contact A360 Workshop Team for extensions.", "phase": CurrentPhase(c)})
+		}
+		return c.Next()
+	}
+}
+
+// RequireView guards routes that read the caller's content
+func RequireView() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !CanView(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account membership expired. Please contact A360 Workshop Team for extensions.", "phase": CurrentPhase(c)})
+		}
+		return c.Next()
+	}
+}
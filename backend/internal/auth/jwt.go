@@ -2,7 +2,9 @@ package auth
 
 import (
 	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/policy"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,51 +12,102 @@ import (
 	"gorm.io/gorm"
 )
 
-func GenerateToken(userID uint) (string, error) {
+// AccessTokenTTL is kept short because access tokens are only checked against
+// their session, clients renew them with the refresh token
+const AccessTokenTTL = 15 * time.Minute
+
+func GenerateToken(userID uint, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+		"sid":     sessionID,
+		"exp":     time.Now().Add(AccessTokenTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 }
 
-func JWTMiddleware() fiber.Handler {
+// Authenticate accepts an access JWT from a session login or a personal access
+// token, both as "Authorization: Bearer <token>"
+func Authenticate(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if len(authHeader) < 8 { // "Bearer " is 7 chars + token
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing or malformed token"})
 		}
-
 		tokenString := authHeader[7:]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
 
-		if err != nil || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token"})
+		var userID uint
+		var sessionID string
+		if strings.HasPrefix(tokenString, APITokenPrefix) {
+			token, err := UseAPIToken(db, tokenString, c.IP())
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+			}
+			userID = token.UserID
+			c.Locals("api_token", token)
+		} else {
+			id, sid, status, msg := parseAccessToken(db, tokenString)
+			if status != 0 {
+				return c.Status(status).JSON(fiber.Map{"error": msg})
+			}
+			userID, sessionID = id, sid
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
+		// Load the user once per request, handlers and guards read it from the context
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User not found"})
 		}
-
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "User ID not found in token"})
+		if !user.IsActive {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Account deactivated. Please contact A360 Workshop Team."})
 		}
 
-		c.Locals("user_id", uint(userID))
+		c.Locals("user_id", user.ID)
+		c.Locals("session_id", sessionID)
+		c.Locals("user", &user)
+		c.Locals("phase", policy.Phase(&user, time.Now()))
 		return c.Next()
 	}
 }
+
+// parseAccessToken validates an access JWT and its session, or returns the error status and message
+func parseAccessToken(db *gorm.DB, tokenString string) (uint, string, int, string) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil || !token.Valid {
+		return 0, "", fiber.StatusUnauthorized, "Invalid token"
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", fiber.StatusUnauthorized, "Invalid token claims"
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, "", fiber.StatusUnauthorized, "User ID not found in token"
+	}
+
+	// Tokens are only as good as their session, so revocation takes effect immediately
+	sessionID, _ := claims["sid"].(string)
+	if !SessionActive(db, sessionID, uint(userID)) {
+		return 0, "", fiber.StatusUnauthorized, "Session expired or revoked"
+	}
+	return uint(userID), sessionID, 0, ""
+}
+
 func AdminMiddleware(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userID := c.Locals("user_id").(uint)
-		var user models.User
-		if err := db.First(&user, userID).Error; err != nil || !user.IsAdmin {
+		user := CurrentUser(c)
+		switch {
+		case Can(user, PermManageUsers):
+		case Can(user, PermManageOrgMembers):
+			// Org-admins see the console confined to their organization
+			c.Locals("org_scope", user.OrganizationID)
+		default:
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admin access required"})
 		}
 		return c.Next()
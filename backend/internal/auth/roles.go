@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"a360-platform/backend/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Roles, from least to most privileged. IsAdmin is kept in sync for admins and
+// super-admins so existing clients and queries keep working.
+const (
+	RoleStudent    = "student"
+	RoleInstructor = "instructor"
+	RoleOrgAdmin   = "org_admin"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "super_admin"
+)
+
+type Permission string
+
+const (
+	PermViewCohortProjects Permission = "projects:view_cohort" // see projects of students in cohorts one instructs
+	PermCommentProjects    Permission = "projects:comment"     // comment on projects one can view
+	PermUnlockProjects     Permission = "projects:unlock"      // temporarily reopen a view-only student's project
+	PermViewOrgProjects    Permission = "projects:view_org"    // see projects of members of one's organization
+	PermViewAllProjects    Permission = "projects:view_all"
+	PermEditAllProjects    Permission = "projects:edit_all"
+	PermBypassLimits       Permission = "limits:bypass" // project limit and storage quota
+	PermManageOrgMembers   Permission = "org:manage"    // the admin console, scoped to one's organization
+	PermManageUsers        Permission = "users:manage"  // the admin console
+	PermManageRoles        Permission = "roles:manage"  // grant or revoke instructor and admin roles
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleStudent:    {PermCommentProjects},
+	RoleInstructor: {PermCommentProjects, PermViewCohortProjects, PermUnlockProjects},
+	RoleOrgAdmin: {PermCommentProjects, PermViewCohortProjects, PermUnlockProjects,
+		PermViewOrgProjects, PermManageOrgMembers},
+	RoleAdmin: {PermCommentProjects, PermViewCohortProjects, PermUnlockProjects,
+		PermViewAllProjects, PermEditAllProjects, PermBypassLimits, PermManageUsers},
+	RoleSuperAdmin: {PermCommentProjects, PermViewCohortProjects, PermUnlockProjects,
+		PermViewAllProjects, PermEditAllProjects, PermBypassLimits, PermManageUsers, PermManageRoles},
+}
+
+// IsRole reports whether role is one of the defined roles
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// UserRole returns the user's role. IsAdmin wins over a stale role so rows from
+// before roles existed, or admins toggled through the old endpoint, stay admins.
+func UserRole(user *models.User) string {
+	switch {
+	case user.IsAdmin && user.Role == RoleSuperAdmin:
+		return RoleSuperAdmin
+	case user.IsAdmin:
+		return RoleAdmin
+	case user.Role == RoleOrgAdmin && user.OrganizationID != nil:
+		return RoleOrgAdmin
+	case user.Role == RoleInstructor:
+		return RoleInstructor
+	}
+	return RoleStudent
+}
+
+// SetRole changes a user's role and keeps IsAdmin in step
+func SetRole(user *models.User, role string) {
+	user.Role = role
+	user.IsAdmin = role == RoleAdmin || role == RoleSuperAdmin
+}
+
+// Can reports whether the user's role grants the permission
+func Can(user *models.User, perm Permission) bool {
+	if user == nil {
+		return false
+	}
+	for _, p := range rolePermissions[UserRole(user)] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// SameOrganization reports whether both users belong to the same organization
+func SameOrganization(a, b *models.User) bool {
+	return a.OrganizationID != nil && b.OrganizationID != nil && *a.OrganizationID == *b.OrganizationID
+}
+
+// RequirePermission guards routes by role permission
+func RequirePermission(perm Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !Can(CurrentUser(c), perm) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not have permission to do this"})
+		}
+		return c.Next()
+	}
+}
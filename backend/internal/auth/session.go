@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+)
+
+// RefreshTokenTTL is how long a session survives without being refreshed
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// Revocation reasons recorded on sessions
+const (
+	RevokedLogout         = "logout"
+	RevokedPasswordReset  = "password_reset"
+	RevokedDeactivated    = "deactivated"
+	RevokedDemoted        = "demoted"
+	RevokedDeleted        = "deleted"
+	RevokedByUser         = "revoked_by_user"
+	RevokedByAdmin        = "revoked_by_admin"
+	RevokedTokenReuse     = "refresh_token_reuse"
+	RevokedTwoFactorReset = "two_factor_reset"
+	RevokedTwoFactorOn    = "two_factor_enabled"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// Tokens is what login, registration and refresh hand back to the client
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      *models.Session
+}
+
+// StartSession creates a session for userID and issues its first token pair
+func StartSession(db *gorm.DB, userID uint, userAgent, ip string) (*Tokens, error) {
+	refresh, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := models.Session{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		RefreshHash: HashToken(refresh),
+		UserAgent:   userAgent,
+		IP:          ip,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(RefreshTokenTTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	access, err := GenerateToken(userID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: access, RefreshToken: refresh, Session: &session}, nil
+}
+
+// RefreshSession rotates the refresh token of a session and issues a new access token.
+// Presenting an already rotated refresh token revokes the session, since one of the
+// two parties holding it must have stolen it.
+func RefreshSession(db *gorm.DB, refreshToken, userAgent, ip string) (*Tokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	hash := HashToken(refreshToken)
+
+	var session models.Session
+	if err := db.Where("refresh_hash = ?", hash).First(&session).Error; err != nil {
+		if db.Where("previous_hash = ? AND revoked_at IS NULL", hash).First(&session).Error == nil {
+			RevokeSession(db, session.ID, RevokedTokenReuse)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := db.First(&user, session.UserID).Error; err != nil || !user.IsActive {
+		RevokeSession(db, session.ID, RevokedDeactivated)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	refresh, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session.PreviousHash = session.RefreshHash
+	session.RefreshHash = HashToken(refresh)
+	session.UserAgent = userAgent
+	session.IP = ip
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(RefreshTokenTTL)
+
+	// Rotate only if nobody else did in the meantime; of two concurrent refreshes
+	// with the same token one loses, and that is treated as reuse
+	res := db.Model(&models.Session{}).Where("id = ? AND refresh_hash = ? AND revoked_at IS NULL", session.ID, hash).Updates(map[string]interface{}{
+		"previous_hash": session.PreviousHash,
+		"refresh_hash":  session.RefreshHash,
+		"user_agent":    userAgent,
+		"ip":            ip,
+		"last_used_at":  now,
+		"expires_at":    session.ExpiresAt,
+	})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected != 1 {
+		RevokeSession(db, session.ID, RevokedTokenReuse)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	access, err := GenerateToken(session.UserID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: access, RefreshToken: refresh, Session: &session}, nil
+}
+
+// SessionActive reports whether the session behind an access token is still valid
+func SessionActive(db *gorm.DB, sessionID string, userID uint) bool {
+	if sessionID == "" {
+		return false
+	}
+	var count int64
+	db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// RevokeSession ends a single session
+func RevokeSession(db *gorm.DB, sessionID, reason string) {
+	db.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+}
+
+// RevokeUserSessions ends every session of a user, signing them out everywhere
+func RevokeUserSessions(db *gorm.DB, userID uint, reason string) {
+	db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+}
+
+// RevokeOtherSessions ends every session of a user except keepID, the one
+// the request came in on
+func RevokeOtherSessions(db *gorm.DB, userID uint, keepID, reason string) {
+	db.Model(&models.Session{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken is what is stored for refresh, API and reset tokens, so a database
+// leak does not leak usable tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
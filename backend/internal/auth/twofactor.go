@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/totp"
+)
+
+// TOTPIssuer names the account in authenticator apps
+const TOTPIssuer = "A360 Workshop"
+
+// ChallengeTTL is how long a user has to enter their code after the password step
+const ChallengeTTL = 5 * time.Minute
+
+// RecoveryCodeCount is how many recovery codes enrolment hands out
+const RecoveryCodeCount = 10
+
+var ErrInvalidChallenge = errors.New("sign-in challenge expired, please sign in again")
+
+// TwoFactorRequired reports whether the user must have two-factor authentication
+// before using the API; REQUIRE_ADMIN_2FA=true enforces it for admins and org-admins
+func TwoFactorRequired(user *models.User) bool {
+	if os.Getenv("REQUIRE_ADMIN_2FA") != "true" {
+		return false
+	}
+	return Can(user, PermManageUsers) || Can(user, PermManageOrgMembers)
+}
+
+// RequireTwoFactor guards routes an admin may only use once enrolled, when enrolment
+// is required. The /auth routes stay open so they can enrol.
+func RequireTwoFactor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := CurrentUser(c)
+		if TwoFactorRequired(user) && !user.TOTPEnabled {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Two-factor authentication is required for admin accounts. Please enable it in your profile.", "two_factor_setup_required": true})
+		}
+		return c.Next()
+	}
+}
+
+// GenerateChallengeToken is handed out after a correct password when a second
+// factor is still needed; it cannot be used as an access token
+func GenerateChallengeToken(userID uint) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"purpose": "2fa",
+		"exp":     time.Now().Add(ChallengeTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// ParseChallengeToken returns the user a challenge token was issued to
+func ParseChallengeToken(tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return 0, ErrInvalidChallenge
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != "2fa" {
+		return 0, ErrInvalidChallenge
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, ErrInvalidChallenge
+	}
+	return uint(userID), nil
+}
+
+// VerifyTOTP checks an authenticator code and records its time step, so each
+// code is accepted once even by concurrent requests
+func VerifyTOTP(db *gorm.DB, user *models.User, code string) bool {
+	if user.TOTPSecret == "" {
+		return false
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false
+	}
+	res := db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// UseRecoveryCode spends one of the user's recovery codes
+func UseRecoveryCode(db *gorm.DB, userID uint, code string) bool {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return false
+	}
+	res := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(code)).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes and returns the new ones
+func GenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	rows := make([]models.RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: HashToken(raw)}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor removes a user's authenticator and recovery codes
+func ResetTwoFactor(db *gorm.DB, user *models.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		user.TOTPSecret = ""
+		user.TOTPEnabled = false
+		user.TOTPLastStep = 0
+		if err := tx.Model(user).Updates(map[string]interface{}{"totp_secret": "", "totp_enabled": false, "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
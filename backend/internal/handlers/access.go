@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/policy"
+)
+
+// Access levels a route needs on the project it addresses
+const (
+	AccessView    = "view"
+	AccessReview  = "review" // private project data: comments, branding, redactions
+	AccessComment = "comment"
+	AccessEdit    = "edit"
+	AccessUnlock  = "unlock"
+)
+
+// How the caller relates to a project, strongest first
+const (
+	relationOwner      = "owner"
+	relationStaff      = "staff"      // role may see every project
+	relationInstructor = "instructor" // instructs a cohort the owner belongs to
+	relationPublic     = "public"     // anyone, while the tour is public
+)
+
+// ProjectAccess loads the project addressed by :id, or the scene in :sceneID and
+// its project, checks the caller's access at the given level and stores them on
+// the context for the handler.
+func (h *ProjectHandler) ProjectAccess(level string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var project models.Project
+		if sceneID := c.Params("sceneID"); sceneID != "" {
+			var scene models.Scene
+			if err := h.DB.Where("id = ?", sceneID).First(&scene).Error; err != nil {
+				return c.Status(404).JSON(fiber.Map{"error": "Scene not found"})
+			}
+			c.Locals("scene", &scene)
+			if err := h.DB.Where("id = ?", scene.ProjectID).First(&project).Error; err != nil {
+				return c.Status(404).JSON(fiber.Map{"error": "Project not found"})
+			}
+		} else if err := h.DB.Where("id = ?", c.Params("id")).First(&project).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Project not found"})
+		}
+
+		user := auth.CurrentUser(c)
+		relation := projectRelation(h.DB, user, &project)
+		if !relationAllows(c, relation, level, &project) {
+			if level == AccessEdit && relation == relationOwner {
+				return c.Status(403).JSON(fiber.Map{"error": "Creative Phase expired. Only View-Only access is allowed. Contact A360 Workshop Team for extensions."})
+			}
+			if level == AccessView {
+				return c.Status(403).JSON(fiber.Map{"error": "Unauthorized or Tour Inactive"})
+			}
+			return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+		}
+
+		c.Locals("project", &project)
+		c.Locals("project_relation", relation)
+		return c.Next()
+	}
+}
+
+func currentProject(c *fiber.Ctx) *models.Project {
+	return c.Locals("project").(*models.Project)
+}
+
+func currentScene(c *fiber.Ctx) *models.Scene {
+	return c.Locals("scene").(*models.Scene)
+}
+
+func projectRelation(db *gorm.DB, user *models.User, project *models.Project) string {
+	switch {
+	case project.UserID == user.ID:
+		return relationOwner
+	case auth.Can(user, auth.PermViewAllProjects):
+		return relationStaff
+	case auth.Can(user, auth.PermViewCohortProjects) && instructs(db, user.ID, project.UserID):
+		return relationInstructor
+	}
+
+	var owner models.User
+	if db.First(&owner, project.UserID).Error != nil {
+		return ""
+	}
+	// Org-admins are staff within their own organization only
+	if auth.Can(user, auth.PermViewOrgProjects) && auth.SameOrganization(user, &owner) {
+		return relationStaff
+	}
+	if project.IsPublic && project.IsActive && policy.Phase(&owner, time.Now()) != policy.PhaseLocked {
+		return relationPublic
+	}
+	return ""
+}
+
+func relationAllows(c *fiber.Ctx, relation, level string, project *models.Project) bool {
+	user := auth.CurrentUser(c)
+	switch level {
+	case AccessView:
+		return relation != ""
+	case AccessReview:
+		return relation == relationOwner || relation == relationStaff || relation == relationInstructor
+	case AccessComment:
+		return (relation == relationOwner || relation == relationStaff || relation == relationInstructor) &&
+			auth.Can(user, auth.PermCommentProjects)
+	case AccessUnlock:
+		return (relation == relationStaff || relation == relationInstructor) && auth.Can(user, auth.PermUnlockProjects)
+	case AccessEdit:
+		return auth.Can(user, auth.PermEditAllProjects) ||
+			relation == relationOwner && (auth.CanEdit(c) || projectUnlocked(project))
+	}
+	return false
+}
+
+// instructs reports whether instructorID runs a cohort that studentID belongs to.
+// The cohort must be in the student's organization, so a cohort never reaches across tenants.
+func instructs(db *gorm.DB, instructorID, studentID uint) bool {
+	var count int64
+	db.Model(&models.User{}).
+		Joins("JOIN cohorts ON cohorts.id = users.cohort_id AND cohorts.deleted_at IS NULL").
+		Where("users.id = ? AND cohorts.instructor_id = ?", studentID, instructorID).
+		Where("cohorts.organization_id IS NOT DISTINCT FROM users.organization_id").
+		Count(&count)
+	return count > 0
+}
+
+// projectUnlocked reports whether an instructor or admin reopened the project for editing
+func projectUnlocked(project *models.Project) bool {
+	return project.UnlockedUntil != nil && time.Now().Before(*project.UnlockedUntil)
+}
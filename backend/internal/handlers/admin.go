@@ -5,40 +5,67 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"a360-platform/backend/internal/auth"
 	"a360-platform/backend/internal/mail"
 	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/org"
+	"a360-platform/backend/internal/policy"
+	"a360-platform/backend/internal/s3"
 )
 
 type AdminHandler struct {
 	DB *gorm.DB
+	R2 *s3.R2Service
 }
 
 func (h *AdminHandler) logAdminAction(adminID uint, action, target, details string) {
+	logAudit(h.DB, adminID, action, target, details)
+}
+
+// logAudit records an action in the audit trail; actorID is the user who performed it
+func logAudit(db *gorm.DB, actorID uint, action, target, details string) {
 	logEntry := models.AuditLog{
-		AdminID:   adminID,
+		AdminID:   actorID,
 		Action:    action,
 		Target:    target,
 		Details:   details,
 		CreatedAt: time.Now(),
 	}
-	h.DB.Create(&logEntry)
+	db.Create(&logEntry)
 }
 
 func (h *AdminHandler) CreateInvitation(c *fiber.Ctx) error {
 	type Request struct {
-		Email   string `json:"email"`
-		IsAdmin bool   `json:"is_admin"`
+		Email          string `json:"email"`
+		IsAdmin        bool   `json:"is_admin"`
+		PolicyID       *uint  `json:"policy_id"` // nil uses the default policy
+		CohortID       *uint  `json:"cohort_id"`
+		OrganizationID *uint  `json:"organization_id"` // platform admins only
 	}
 	var req Request
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	orgID, ok := h.targetOrganization(c, req.OrganizationID)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Organization not found"})
+	}
+	if !h.policyExists(req.PolicyID) {
+		return c.Status(400).JSON(fiber.Map{"error": "Policy not found"})
+	}
+	if !h.cohortExists(c, req.CohortID) {
+		return c.Status(400).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+	if req.IsAdmin && !auth.Can(auth.CurrentUser(c), auth.PermManageRoles) {
+		return c.Status(403).JSON(fiber.Map{"error": "Only super-admins can invite admins"})
+	}
 
 	// Check if user is already registered
 	var existingUser models.User
@@ -52,10 +79,13 @@ func (h *AdminHandler) CreateInvitation(c *fiber.Ctx) error {
 	token := hex.EncodeToString(b)
 
 	invitation := models.Invitation{
-		Email:     req.Email,
-		Token:     token,
-		IsAdmin:   req.IsAdmin,
-		ExpiresAt: time.Now().AddDate(0, 3, 0), // 3 months expiry
+		Email:          req.Email,
+		Token:          token,
+		IsAdmin:        req.IsAdmin,
+		PolicyID:       req.PolicyID,
+		CohortID:       req.CohortID,
+		OrganizationID: orgID,
+		ExpiresAt:      time.Now().AddDate(0, 3, 0), // 3 months expiry
 	}
 
 	if err := h.DB.Create(&invitation).Error; err != nil {
@@ -63,7 +93,7 @@ func (h *AdminHandler) CreateInvitation(c *fiber.Ctx) error {
 	}
 
 	// Send Email
-	if err := mail.SendInvitation(req.Email, token); err != nil {
+	if err := mail.SendInvitation(org.Sender(h.DB, orgID), req.Email, token); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to send invitation email: " + err.Error()})
 	}
 
@@ -77,7 +107,10 @@ func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
 	offset := (page - 1) * limit
 
 	var users []models.User
-	query := h.DB.Model(&models.User{})
+	query := scoped(c, h.DB.Model(&models.User{}), "organization_id")
+	if v := c.Query("organization_id"); v != "" && auth.OrgScope(c) == nil {
+		query = query.Where("organization_id = ?", v)
+	}
 
 	if search != "" {
 		query = query.Where("email ILIKE ?", "%"+search+"%")
@@ -104,28 +137,44 @@ func (h *AdminHandler) GetStats(c *fiber.Ctx) error {
 	var activeCodes int64
 	var totalSpace int64
 
-	h.DB.Model(&models.User{}).Count(&totalUsers)
-	h.DB.Model(&models.RegistrationCode{}).Count(&totalCodes)
-	h.DB.Model(&models.RegistrationCode{}).Where("is_active = ? AND expires_at > ?", true, time.Now()).Count(&activeCodes)
-	h.DB.Model(&models.User{}).Select("SUM(storage_used)").Row().Scan(&totalSpace)
+	var totalProjects int64
+
+	// Org-admins see their organization's figures, platform admins may ask for one
+	users := func() *gorm.DB { return h.statsScope(c, h.DB.Model(&models.User{}), "organization_id") }
+	codes := func() *gorm.DB { return h.statsScope(c, h.DB.Model(&models.RegistrationCode{}), "organization_id") }
+
+	users().Count(&totalUsers)
+	codes().Count(&totalCodes)
+	codes().Where("is_active = ? AND expires_at > ?", true, time.Now()).Count(&activeCodes)
+	users().Select("COALESCE(SUM(storage_used), 0)").Row().Scan(&totalSpace)
+	h.statsScope(c, h.DB.Model(&models.Project{}).Joins("JOIN users ON users.id = projects.user_id AND users.deleted_at IS NULL"), "users.organization_id").Count(&totalProjects)
 
 	return c.JSON(fiber.Map{
-		"total_users":  totalUsers,
-		"total_codes":  totalCodes,
-		"active_codes": activeCodes,
-		"total_space":  totalSpace,
+		"total_users":    totalUsers,
+		"total_codes":    totalCodes,
+		"active_codes":   activeCodes,
+		"total_space":    totalSpace,
+		"total_projects": totalProjects,
 	})
 }
 
+// statsScope scopes a stats query like scoped, also honouring ?organization_id= for platform admins
+func (h *AdminHandler) statsScope(c *fiber.Ctx, query *gorm.DB, column string) *gorm.DB {
+	if v := c.Query("organization_id"); v != "" && auth.OrgScope(c) == nil {
+		return query.Where(column+" = ?", v)
+	}
+	return scoped(c, query, column)
+}
+
 func (h *AdminHandler) ListInvitations(c *fiber.Ctx) error {
 	var invitations []models.Invitation
-	h.DB.Order("created_at desc").Find(&invitations)
+	scoped(c, h.DB, "organization_id").Order("created_at desc").Find(&invitations)
 	return c.JSON(invitations)
 }
 
 func (h *AdminHandler) DeleteInvitation(c *fiber.Ctx) error {
 	id := c.Params("id")
-	if err := h.DB.Unscoped().Delete(&models.Invitation{}, id).Error; err != nil {
+	if err := scoped(c, h.DB.Unscoped(), "organization_id").Delete(&models.Invitation{}, id).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete invitation"})
 	}
 
@@ -138,9 +187,12 @@ func (h *AdminHandler) DeleteInvitation(c *fiber.Ctx) error {
 func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var user models.User
-	if err := h.DB.First(&user, id).Error; err != nil {
+	if err := scoped(c, h.DB, "organization_id").First(&user, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
+	if !manageable(c, &user) {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot manage this account"})
+	}
 
 	// Safety: Don't allow an admin to delete themselves via this endpoint
 	// (though frontend usually prevents it)
@@ -173,6 +225,11 @@ func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
 		// Purge invitation
 		tx.Unscoped().Where("email = ?", user.Email).Delete(&models.Invitation{})
 
+		// Purge sessions, which also invalidates any access token still in flight
+		tx.Where("user_id = ?", user.ID).Delete(&models.Session{})
+		tx.Where("user_id = ?", user.ID).Delete(&models.APIToken{})
+		tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{})
+
 		return nil
 	})
 
@@ -185,6 +242,7 @@ func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
 	// 3. Clean up physical files AFTER successful DB transaction
 	for _, p := range projects {
 		os.RemoveAll(p.PanoPath)
+		os.RemoveAll(filepath.Join("private", p.ID))
 	}
 
 	return c.JSON(fiber.Map{"message": "User and all associated data deleted permanently"})
@@ -192,19 +250,32 @@ func (h *AdminHandler) DeleteUser(c *fiber.Ctx) error {
 
 func (h *AdminHandler) CreateRegistrationCode(c *fiber.Ctx) error {
 	type Request struct {
-		Code         string `json:"code"`
-		Description  string `json:"description"`
-		MaxUsage     int    `json:"max_usage"`
-		ProjectLimit int    `json:"project_limit"`
-		StorageQuota int64  `json:"storage_quota"`
-		ExpiryMonths int    `json:"expiry_months"` // 3, 6, 12
-		IsActive     bool   `json:"is_active"`
-		ValidFrom    string `json:"valid_from"` // ISO string
+		Code           string `json:"code"`
+		Description    string `json:"description"`
+		MaxUsage       int    `json:"max_usage"`
+		ProjectLimit   int    `json:"project_limit"`
+		StorageQuota   int64  `json:"storage_quota"`
+		ExpiryMonths   int    `json:"expiry_months"` // 3, 6, 12
+		IsActive       bool   `json:"is_active"`
+		ValidFrom      string `json:"valid_from"` // ISO string
+		PolicyID       *uint  `json:"policy_id"`  // nil uses the default policy
+		CohortID       *uint  `json:"cohort_id"`
+		OrganizationID *uint  `json:"organization_id"` // platform admins only
 	}
 	var req Request
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	orgID, ok := h.targetOrganization(c, req.OrganizationID)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Organization not found"})
+	}
+	if !h.policyExists(req.PolicyID) {
+		return c.Status(400).JSON(fiber.Map{"error": "Policy not found"})
+	}
+	if !h.cohortExists(c, req.CohortID) {
+		return c.Status(400).JSON(fiber.Map{"error": "Cohort not found"})
+	}
 
 	if len(req.Code) > 6 {
 		return c.Status(400).JSON(fiber.Map{"error": "Code must be 6 characters maximum"})
@@ -225,15 +296,28 @@ func (h *AdminHandler) CreateRegistrationCode(c *fiber.Ctx) error {
 		expiryDate = expiryDate.AddDate(0, 3, 0)
 	}
 
+	// Codes without their own quotas carry the organization's defaults
+	if o := org.Find(h.DB, orgID); o != nil {
+		if req.ProjectLimit == 0 {
+			req.ProjectLimit = o.DefaultProjectLimit
+		}
+		if req.StorageQuota == 0 {
+			req.StorageQuota = o.DefaultStorageQuota
+		}
+	}
+
 	regCode := models.RegistrationCode{
-		Code:         req.Code,
-		Description:  req.Description,
-		MaxUsage:     req.MaxUsage,
-		ProjectLimit: req.ProjectLimit,
-		StorageQuota: req.StorageQuota,
-		IsActive:     req.IsActive,
-		ValidFrom:    validFrom,
-		ExpiresAt:    expiryDate,
+		Code:           req.Code,
+		Description:    req.Description,
+		MaxUsage:       req.MaxUsage,
+		ProjectLimit:   req.ProjectLimit,
+		StorageQuota:   req.StorageQuota,
+		IsActive:       req.IsActive,
+		PolicyID:       req.PolicyID,
+		CohortID:       req.CohortID,
+		OrganizationID: orgID,
+		ValidFrom:      validFrom,
+		ExpiresAt:      expiryDate,
 	}
 
 	if err := h.DB.Create(&regCode).Error; err != nil {
@@ -248,14 +332,14 @@ func (h *AdminHandler) CreateRegistrationCode(c *fiber.Ctx) error {
 
 func (h *AdminHandler) ListRegistrationCodes(c *fiber.Ctx) error {
 	var codes []models.RegistrationCode
-	h.DB.Find(&codes)
+	scoped(c, h.DB, "organization_id").Find(&codes)
 	return c.JSON(codes)
 }
 
 func (h *AdminHandler) DeleteRegistrationCode(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var code models.RegistrationCode
-	if err := h.DB.First(&code, id).Error; err != nil {
+	if err := scoped(c, h.DB, "organization_id").First(&code, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Code not found"})
 	}
 
@@ -275,7 +359,7 @@ func (h *AdminHandler) DeleteRegistrationCode(c *fiber.Ctx) error {
 func (h *AdminHandler) ToggleRegistrationCode(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var code models.RegistrationCode
-	if err := h.DB.First(&code, id).Error; err != nil {
+	if err := scoped(c, h.DB, "organization_id").First(&code, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Code not found"})
 	}
 
@@ -291,18 +375,25 @@ func (h *AdminHandler) ToggleRegistrationCode(c *fiber.Ctx) error {
 func (h *AdminHandler) ToggleActive(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var user models.User
-	if err := h.DB.First(&user, id).Error; err != nil {
+	if err := scoped(c, h.DB, "organization_id").First(&user, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
+	if !manageable(c, &user) {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot manage this account"})
+	}
 
-	if user.Email == "admin@example.com" {
-		return c.Status(400).JSON(fiber.Map{"error": "Cannot deactivate super admin"})
+	adminID := c.Locals("user_id").(uint)
+	if adminID == user.ID {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot deactivate your own account"})
 	}
 
 	user.IsActive = !user.IsActive
+	user.CohortDisabled = false
 	h.DB.Save(&user)
+	if !user.IsActive {
+		auth.RevokeUserSessions(h.DB, user.ID, auth.RevokedDeactivated)
+	}
 
-	adminID := c.Locals("user_id").(uint)
 	status := "deactivated"
 	if user.IsActive {
 		status = "activated"
@@ -314,38 +405,120 @@ func (h *AdminHandler) ToggleActive(c *fiber.Ctx) error {
 func (h *AdminHandler) ToggleAdmin(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var user models.User
-	if err := h.DB.First(&user, id).Error; err != nil {
+	if err := scoped(c, h.DB, "organization_id").First(&user, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	user.IsAdmin = !user.IsAdmin
-	h.DB.Save(&user)
+	role := auth.RoleAdmin
+	if user.IsAdmin {
+		role = auth.RoleStudent
+	}
+	return h.changeRole(c, &user, role)
+}
 
+func (h *AdminHandler) SetUserRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var user models.User
+	if err := scoped(c, h.DB, "organization_id").First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	type Request struct {
+		Role string `json:"role"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil || !auth.IsRole(req.Role) {
+		return c.Status(400).JSON(fiber.Map{"error": "Role must be student, instructor, org_admin, admin or super_admin"})
+	}
+	if req.Role == auth.RoleOrgAdmin && user.OrganizationID == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Org-admins must belong to an organization"})
+	}
+	return h.changeRole(c, &user, req.Role)
+}
+
+func (h *AdminHandler) changeRole(c *fiber.Ctx, user *models.User, role string) error {
 	adminID := c.Locals("user_id").(uint)
-	h.logAdminAction(adminID, "Toggle User Admin", user.Email, fmt.Sprintf("IsAdmin: %v", user.IsAdmin))
+	previous := auth.UserRole(user)
+	if user.ID == adminID && previous == auth.RoleSuperAdmin && role != auth.RoleSuperAdmin {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot remove your own super-admin role"})
+	}
+
+	auth.SetRole(user, role)
+	h.DB.Model(user).Updates(map[string]interface{}{"role": user.Role, "is_admin": user.IsAdmin})
+
+	// Permissions may be cached in clients, make demoted users sign in again
+	if rolePrivilege(role) < rolePrivilege(previous) {
+		auth.RevokeUserSessions(h.DB, user.ID, auth.RevokedDemoted)
+	}
+
+	h.logAdminAction(adminID, "Change User Role", user.Email, fmt.Sprintf("%s -> %s", previous, role))
 
 	return c.JSON(user)
 }
 
+func rolePrivilege(role string) int {
+	switch role {
+	case auth.RoleInstructor:
+		return 1
+	case auth.RoleOrgAdmin:
+		return 2
+	case auth.RoleAdmin:
+		return 3
+	case auth.RoleSuperAdmin:
+		return 4
+	}
+	return 0
+}
+
 func (h *AdminHandler) UpdateUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var user models.User
-	if err := h.DB.First(&user, id).Error; err != nil {
+	if err := scoped(c, h.DB, "organization_id").First(&user, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
+	if !manageable(c, &user) {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot manage this account"})
+	}
 
 	type Request struct {
-		FullName     string `json:"full_name"`
-		UserType     string `json:"user_type"`
-		StorageQuota int64  `json:"storage_quota"`
-		ProjectLimit int    `json:"project_limit"`
-		ValidFrom    string `json:"valid_from"`
-		ExpiresAt    string `json:"expires_at"`
+		FullName       string `json:"full_name"`
+		UserType       string `json:"user_type"`
+		StorageQuota   int64  `json:"storage_quota"`
+		ProjectLimit   int    `json:"project_limit"`
+		ValidFrom      string `json:"valid_from"`
+		ExpiresAt      string `json:"expires_at"`
+		LocksAt        string `json:"locks_at"`
+		PolicyID       *uint  `json:"policy_id"`       // re-applies the policy from valid_from, explicit dates still win
+		OrganizationID *uint  `json:"organization_id"` // platform admins only, 0 removes the user from their organization
 	}
 	var req Request
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	if req.OrganizationID != nil {
+		if auth.OrgScope(c) != nil {
+			return c.Status(403).JSON(fiber.Map{"error": "Only platform admins can move users between organizations"})
+		}
+		if *req.OrganizationID == 0 {
+			user.OrganizationID = nil
+		} else if h.organizationExists(req.OrganizationID) {
+			user.OrganizationID = req.OrganizationID
+		} else {
+			return c.Status(400).JSON(fiber.Map{"error": "Organization not found"})
+		}
+		// An org-admin without an organization would fall back to a student
+		if user.OrganizationID == nil && user.Role == auth.RoleOrgAdmin {
+			auth.SetRole(&user, auth.RoleStudent)
+		}
+	}
+	user.LocksAt = policy.LocksAt(&user)
+	if req.PolicyID != nil {
+		var p models.MembershipPolicy
+		if err := h.DB.First(&p, *req.PolicyID).Error; err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Policy not found"})
+		}
+		policy.Apply(&user, p, user.ValidFrom)
+	}
 
 	if req.ValidFrom != "" {
 		if t, err := time.Parse(time.RFC3339, req.ValidFrom); err == nil {
@@ -354,9 +527,19 @@ func (h *AdminHandler) UpdateUser(c *fiber.Ctx) error {
 	}
 	if req.ExpiresAt != "" {
 		if t, err := time.Parse(time.RFC3339, req.ExpiresAt); err == nil {
+			// Extending the creative phase keeps the view-only period that follows it
+			user.LocksAt = policy.LocksAt(&user).Add(t.Sub(user.ExpiresAt))
 			user.ExpiresAt = t
 		}
 	}
+	if req.LocksAt != "" {
+		if t, err := time.Parse(time.RFC3339, req.LocksAt); err == nil {
+			user.LocksAt = t
+		}
+	}
+	if user.LocksAt.Before(user.ExpiresAt) {
+		return c.Status(400).JSON(fiber.Map{"error": "Lockout date cannot be before the end of the creative phase"})
+	}
 
 	if req.FullName != "" {
 		user.FullName = req.FullName
@@ -398,3 +581,28 @@ func (h *AdminHandler) GetAuditLogs(c *fiber.Ctx) error {
 	h.DB.Order("created_at desc").Limit(100).Find(&logs)
 	return c.JSON(logs)
 }
+
+func (h *AdminHandler) ListUserSessions(c *fiber.Ctx) error {
+	var sessions []models.Session
+	membersOnly(c, h.DB, "user_id").Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", c.Params("id"), time.Now()).Order("last_used_at desc").Find(&sessions)
+	return c.JSON(sessions)
+}
+
+func (h *AdminHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var user models.User
+	if err := scoped(c, h.DB, "organization_id").First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !manageable(c, &user) {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot manage this account"})
+	}
+
+	auth.RevokeUserSessions(h.DB, user.ID, auth.RevokedByAdmin)
+	auth.RevokeUserAPITokens(h.DB, user.ID)
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Revoke Sessions", user.Email, "Signed out of all devices, API tokens revoked")
+
+	return c.JSON(fiber.Map{"message": "All sessions and API tokens revoked"})
+}
@@ -13,6 +13,8 @@ import (
 	"a360-platform/backend/internal/auth"
 	"a360-platform/backend/internal/mail"
 	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/org"
+	"a360-platform/backend/internal/policy"
 )
 
 type AuthHandler struct {
@@ -84,24 +86,23 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	storageQuota := int64(300 * 1024 * 1024) // Default 300MB
 	isAdmin := false
 	regSource := ""
-	validFrom := time.Now()
-	expiresAt := time.Now().AddDate(0, 12, 0) // Default 12 months for membership
+	var policyID, cohortID, orgID *uint
 
 	if isInvite {
 		isAdmin = invitation.IsAdmin
 		regSource = "Invitation"
-		// Invitations: Individual access starts upon registration
-		validFrom = time.Now()
-		expiresAt = validFrom.AddDate(0, 12, 0) // Fixed 12 months from registration
+		policyID = invitation.PolicyID
+		cohortID = invitation.CohortID
+		orgID = invitation.OrganizationID
 	} else if isRegCode {
 		projectLimit = regCode.ProjectLimit
 		if regCode.StorageQuota > 0 {
 			storageQuota = regCode.StorageQuota
 		}
 		regSource = "Code:" + regCode.Code
-		// Seminar codes: Individual access starts upon registration
-		validFrom = time.Now()
-		expiresAt = validFrom.AddDate(0, 12, 0) // Fixed 12 months from registration
+		policyID = regCode.PolicyID
+		cohortID = regCode.CohortID
+		orgID = regCode.OrganizationID
 	}
 
 	user := models.User{
@@ -112,10 +113,47 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		// Set default quota and non-admin status
 		StorageQuota: storageQuota,
 		ProjectLimit: projectLimit,
-		IsAdmin:      isAdmin,
 		RegSource:    regSource,
-		ValidFrom:    validFrom,
-		ExpiresAt:    expiresAt,
+	}
+	if isAdmin {
+		auth.SetRole(&user, auth.RoleAdmin)
+	} else {
+		auth.SetRole(&user, auth.RoleStudent)
+	}
+	// Cohort members share the workshop's policy and dates
+	var cohort models.Cohort
+	if cohortID != nil && h.DB.First(&cohort, *cohortID).Error == nil {
+		if !cohort.IsActive {
+			return c.Status(403).JSON(fiber.Map{"error": "This cohort is no longer accepting registrations"})
+		}
+		user.CohortID = &cohort.ID
+		if policyID == nil {
+			policyID = cohort.PolicyID
+		}
+		if orgID == nil {
+			orgID = cohort.OrganizationID
+		}
+	}
+	// Organization quotas stand in for the platform defaults, a code's own quota still wins
+	if orgID != nil {
+		o := org.Find(h.DB, orgID)
+		if o == nil {
+			return c.Status(403).JSON(fiber.Map{"error": "This organization is no longer accepting registrations"})
+		}
+		org.ApplyQuotas(&user, o)
+		if isRegCode {
+			user.ProjectLimit = projectLimit
+			if regCode.StorageQuota > 0 {
+				user.StorageQuota = storageQuota
+			}
+		}
+	}
+
+	// Individual access starts upon registration, for invitations and seminar codes alike
+	membership := policy.Resolve(h.DB, policyID)
+	policy.Apply(&user, membership, time.Now())
+	if user.CohortID != nil {
+		policy.ApplyCohort(&user, cohort)
 	}
 
 	if err := h.DB.Create(&user).Error; err != nil {
@@ -129,12 +167,15 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		h.DB.Model(&regCode).Update("usage_count", regCode.UsageCount+1)
 	}
 
-	token, _ := auth.GenerateToken(user.ID)
+	tokens, err := auth.StartSession(h.DB, user.ID, c.Get("User-Agent"), c.IP())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start session"})
+	}
 
 	// Send Welcome Email
-	_ = mail.SendWelcome(user.Email)
+	_ = mail.SendWelcome(org.Sender(h.DB, user.OrganizationID), user.Email, membership.CreativeDays, membership.ViewOnlyDays)
 
-	return c.JSON(fiber.Map{"token": token, "user": user})
+	return c.JSON(fiber.Map{"token": tokens.AccessToken, "refresh_token": tokens.RefreshToken, "user": user})
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -158,23 +199,102 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
 	}
 
+	if !user.IsActive {
+		return c.Status(403).JSON(fiber.Map{"error": "Account deactivated. Please contact A360 Workshop Team."})
+	}
+
 	log.Printf("[LOGIN DEBUG] Successful login for email: [%s]", req.Email)
 
 	// Total Lockout Check (Tiered Access: Phase 3 - After 90 days total)
-	if !user.IsAdmin && time.Now().After(user.ExpiresAt.AddDate(0, 0, 60)) {
-		return c.Status(403).JSON(fiber.Map{"error": "Account membership expired (90-day institutional limit reached). Please contact A360 Workshop Team for extensions."})
+	user.Phase = policy.Phase(&user, time.Now())
+	if user.Phase == policy.PhaseLocked {
+		return c.Status(403).JSON(fiber.Map{"error": "Account membership expired. Please contact A360 Workshop Team for extensions."})
+	}
+
+	// The password alone is not enough once an authenticator is enrolled
+	if user.TOTPEnabled {
+		challenge, err := auth.GenerateChallengeToken(user.ID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to start sign-in"})
+		}
+		return c.JSON(fiber.Map{"two_factor_required": true, "challenge_token": challenge})
+	}
+
+	return h.completeLogin(c, &user)
+}
+
+// completeLogin starts a session for a fully authenticated user
+func (h *AuthHandler) completeLogin(c *fiber.Ctx, user *models.User) error {
+	tokens, err := auth.StartSession(h.DB, user.ID, c.Get("User-Agent"), c.IP())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start session"})
+	}
+	return c.JSON(fiber.Map{"token": tokens.AccessToken, "refresh_token": tokens.RefreshToken, "user": user, "two_factor_setup_required": auth.TwoFactorRequired(user) && !user.TOTPEnabled})
+}
+
+// Refresh trades a refresh token for a new access token and a rotated refresh token
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	type Request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	token, _ := auth.GenerateToken(user.ID)
-	return c.JSON(fiber.Map{"token": token, "user": user})
+	tokens, err := auth.RefreshSession(h.DB, req.RefreshToken, c.Get("User-Agent"), c.IP())
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Session expired. Please sign in again."})
+	}
+	return c.JSON(fiber.Map{"token": tokens.AccessToken, "refresh_token": tokens.RefreshToken})
+}
+
+// Logout revokes the session of the calling access token
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	auth.RevokeSession(h.DB, c.Locals("session_id").(string), auth.RevokedLogout)
+	return c.JSON(fiber.Map{"message": "Signed out"})
+}
+
+// ListSessions returns the caller's active sessions, flagging the current one
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	current := c.Locals("session_id").(string)
+
+	var sessions []models.Session
+	h.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).Order("last_used_at desc").Find(&sessions)
+
+	type SessionResponse struct {
+		models.Session
+		Current bool `json:"current"`
+	}
+	res := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		res[i] = SessionResponse{Session: s, Current: s.ID == current}
+	}
+	return c.JSON(res)
+}
+
+// RevokeSession signs one of the caller's own sessions out
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var session models.Session
+	if err := h.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&session).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+	}
+	auth.RevokeSession(h.DB, session.ID, auth.RevokedByUser)
+	return c.JSON(fiber.Map{"message": "Session revoked"})
 }
 
 func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
 	var user models.User
-	if err := h.DB.Preload("Projects").First(&user, userID).Error; err != nil {
+	if err := h.DB.Preload("Projects").Preload("Policy").Preload("Organization").First(&user, userID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
+	// Exact phase dates travel with the user: expires_at ends creation, locks_at ends viewing
+	user.Phase = auth.CurrentPhase(c)
+	user.LocksAt = policy.LocksAt(&user)
 	return c.JSON(user)
 }
 
@@ -211,12 +331,13 @@ func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
 	token := hex.EncodeToString(b)
 	expires := time.Now().Add(1 * time.Hour)
 
-	user.ResetToken = token
+	// Only the hash is stored, the token itself goes out by email
+	user.ResetToken = auth.HashToken(token)
 	user.ResetExpires = &expires
-	h.DB.Save(&user)
+	h.DB.Model(&user).Updates(map[string]interface{}{"reset_token": user.ResetToken, "reset_expires": expires})
 
 	// Send Email
-	if err := mail.SendResetPassword(user.Email, token); err != nil {
+	if err := mail.SendResetPassword(org.Sender(h.DB, user.OrganizationID), user.Email, token); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to send reset email"})
 	}
 
@@ -234,7 +355,7 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	}
 
 	var user models.User
-	if err := h.DB.Where("reset_token = ? AND reset_expires > ?", req.Token, time.Now()).First(&user).Error; err != nil {
+	if err := h.DB.Where("reset_token = ? AND reset_expires > ?", auth.HashToken(req.Token), time.Now()).First(&user).Error; err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid or expired reset token"})
 	}
 
@@ -244,6 +365,10 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	user.ResetExpires = nil
 	h.DB.Save(&user)
 
+	// Whoever knew the old password is signed out everywhere, and so are their scripts
+	auth.RevokeUserSessions(h.DB, user.ID, auth.RevokedPasswordReset)
+	auth.RevokeUserAPITokens(h.DB, user.ID)
+
 	return c.JSON(fiber.Map{"message": "Password updated successfully"})
 }
 
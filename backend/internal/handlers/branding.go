@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/pipeline"
+	"a360-platform/backend/internal/s3"
+)
+
+// findBranding returns the project's own branding, falling back to the default of
+// the owner's organization and then to the platform default
+func findBranding(db *gorm.DB, projectID string) *models.Branding {
+	var b models.Branding
+	if err := db.Where("project_id = ?", projectID).First(&b).Error; err == nil {
+		return &b
+	}
+	var project models.Project
+	if err := db.Preload("User").Where("id = ?", projectID).First(&project).Error; err == nil && project.User != nil {
+		return defaultBranding(db, project.User.OrganizationID)
+	}
+	return defaultBranding(db, nil)
+}
+
+// defaultBranding returns an organization's default branding, falling back to the platform default
+func defaultBranding(db *gorm.DB, orgID *uint) *models.Branding {
+	var b models.Branding
+	if orgID != nil && db.Where("project_id IS NULL AND organization_id = ?", *orgID).First(&b).Error == nil {
+		return &b
+	}
+	if db.Where("project_id IS NULL AND organization_id IS NULL").First(&b).Error == nil {
+		return &b
+	}
+	return nil
+}
+
+// loadBranding resolves and decodes the branding artwork the pipeline should apply to a project
+func loadBranding(db *gorm.DB, r2 *s3.R2Service, projectID string) *pipeline.Branding {
+	b := findBranding(db, projectID)
+	if b == nil || (b.NadirPath == "" && b.WatermarkPath == "") {
+		return nil
+	}
+	return &pipeline.Branding{
+		Nadir:              loadBrandingAsset(r2, b.NadirPath),
+		NadirScale:         b.NadirScale,
+		Watermark:          loadBrandingAsset(r2, b.WatermarkPath),
+		WatermarkOpacity:   b.WatermarkOpacity,
+		WatermarkThumbnail: b.WatermarkThumbnail,
+	}
+}
+
+func loadBrandingAsset(r2 *s3.R2Service, path string) image.Image {
+	if path == "" {
+		return nil
+	}
+	local := path
+	if r2 != nil {
+		tmp, err := os.CreateTemp("", "branding-*"+filepath.Ext(path))
+		if err != nil {
+			return nil
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if err := r2.DownloadFile(context.Background(), path, tmp.Name()); err != nil {
+			return nil
+		}
+		local = tmp.Name()
+	}
+	img, err := imaging.Open(local)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// saveBrandingAsset stores an uploaded branding image and returns its R2 key or local path.
+// It returns an empty path when the form has no such file.
+func saveBrandingAsset(c *fiber.Ctx, r2 *s3.R2Service, field, scope string) (string, error) {
+	file, err := c.FormFile(field)
+	if err != nil {
+		return "", nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return "", fmt.Errorf("%s must be a PNG or JPEG image", field)
+	}
+
+	dir := fmt.Sprintf("uploads/branding/%s", scope)
+	os.MkdirAll("./"+dir, 0755)
+	name := fmt.Sprintf("%s-%s%s", field, uuid.New().String(), ext)
+	localPath := filepath.Join(".", dir, name)
+	if err := c.SaveFile(file, localPath); err != nil {
+		return "", err
+	}
+	if _, err := imaging.Open(localPath); err != nil {
+		os.Remove(localPath)
+		return "", fmt.Errorf("%s is not a readable image", field)
+	}
+
+	if r2 != nil {
+		key := fmt.Sprintf("branding/%s/%s", scope, name)
+		contentType := "image/png"
+		if ext != ".png" {
+			contentType = "image/jpeg"
+		}
+		err := r2.UploadFile(context.Background(), key, localPath, contentType)
+		os.Remove(localPath)
+		return key, err
+	}
+	return dir + "/" + name, nil
+}
+
+// applyBrandingForm updates b from a multipart branding request
+func applyBrandingForm(c *fiber.Ctx, r2 *s3.R2Service, b *models.Branding, scope string) error {
+	if c.FormValue("clear_nadir") == "true" {
+		b.NadirPath = ""
+	} else if path, err := saveBrandingAsset(c, r2, "nadir", scope); err != nil {
+		return err
+	} else if path != "" {
+		b.NadirPath = path
+	}
+
+	if c.FormValue("clear_watermark") == "true" {
+		b.WatermarkPath = ""
+	} else if path, err := saveBrandingAsset(c, r2, "watermark", scope); err != nil {
+		return err
+	} else if path != "" {
+		b.WatermarkPath = path
+	}
+
+	if v := c.FormValue("nadir_scale"); v != "" {
+		scale, err := strconv.ParseFloat(v, 64)
+		if err != nil || scale <= 0 || scale > 1 {
+			return fmt.Errorf("nadir_scale must be between 0 and 1")
+		}
+		b.NadirScale = scale
+	}
+	if v := c.FormValue("watermark_opacity"); v != "" {
+		opacity, err := strconv.ParseFloat(v, 64)
+		if err != nil || opacity <= 0 || opacity > 1 {
+			return fmt.Errorf("watermark_opacity must be between 0 and 1")
+		}
+		b.WatermarkOpacity = opacity
+	}
+	if v := c.FormValue("watermark_thumbnail"); v != "" {
+		b.WatermarkThumbnail = v == "true"
+	}
+	return nil
+}
+
+// resliceProject queues every ready scene of a project for re-slicing; each
+// one waits for a slot on the slicing semaphore like a new upload does
+func resliceProject(db *gorm.DB, r2 *s3.R2Service, pid string) {
+	var project models.Project
+	if err := db.Where("id = ?", pid).First(&project).Error; err != nil {
+		return
+	}
+
+	var scenes []models.Scene
+	db.Where("project_id = ? AND status = ?", pid, "ready").Find(&scenes)
+	for _, scene := range scenes {
+		go resliceScene(db, r2, scene, sceneOptions(scene, project))
+	}
+}
+
+func (h *ProjectHandler) GetProjectBranding(c *fiber.Ctx) error {
+	b := findBranding(h.DB, currentProject(c).ID)
+	inherited := b == nil || b.ProjectID == nil
+	return c.JSON(fiber.Map{"branding": b, "inherited": inherited})
+}
+
+func (h *ProjectHandler) UpdateProjectBranding(c *fiber.Ctx) error {
+	id := c.Params("id")
+	project := *currentProject(c)
+
+	var b models.Branding
+	if err := h.DB.Where("project_id = ?", id).First(&b).Error; err != nil {
+		// Start from the default so unspecified settings keep their current effect
+		if def := findBranding(h.DB, id); def != nil {
+			b = *def
+		}
+		b.ID = 0
+		b.ProjectID = &project.ID
+		b.OrganizationID = nil
+	}
+
+	if err := applyBrandingForm(c, h.R2, &b, project.ID); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.DB.Save(&b).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save branding"})
+	}
+
+	go resliceProject(h.DB, h.R2, project.ID)
+
+	return c.JSON(b)
+}
+
+func (h *ProjectHandler) DeleteProjectBranding(c *fiber.Ctx) error {
+	id := c.Params("id")
+	project := *currentProject(c)
+
+	h.DB.Where("project_id = ?", id).Delete(&models.Branding{})
+
+	go resliceProject(h.DB, h.R2, project.ID)
+
+	return c.JSON(fiber.Map{"message": "Project branding reset to default"})
+}
+
+// brandingOrganization returns the organization whose default branding the admin
+// addresses: an org-admin's own, or ?organization_id= for platform admins
+func (h *AdminHandler) brandingOrganization(c *fiber.Ctx) (*uint, bool) {
+	if org := auth.OrgScope(c); org != nil {
+		return org, true
+	}
+	v := c.Query("organization_id")
+	if v == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	org := uint(id)
+	return &org, h.organizationExists(&org)
+}
+
+func (h *AdminHandler) GetDefaultBranding(c *fiber.Ctx) error {
+	orgID, ok := h.brandingOrganization(c)
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+	b := defaultBranding(h.DB, orgID)
+	inherited := b != nil && orgID != nil && b.OrganizationID == nil
+	return c.JSON(fiber.Map{"branding": b, "inherited": inherited})
+}
+
+func (h *AdminHandler) UpdateDefaultBranding(c *fiber.Ctx) error {
+	orgID, ok := h.brandingOrganization(c)
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+
+	var b models.Branding
+	scope, target := "default", "Branding"
+	if orgID == nil {
+		h.DB.Where("project_id IS NULL AND organization_id IS NULL").First(&b)
+	} else if err := h.DB.Where("project_id IS NULL AND organization_id = ?", *orgID).First(&b).Error; err != nil {
+		// Start from the platform default so unspecified settings keep their current effect
+		if def := defaultBranding(h.DB, nil); def != nil {
+			b = *def
+		}
+		b.ID = 0
+		b.OrganizationID = orgID
+	}
+	if orgID != nil {
+		scope = fmt.Sprintf("org-%d", *orgID)
+		target = fmt.Sprintf("Branding: Organization %d", *orgID)
+	}
+
+	if err := applyBrandingForm(c, h.R2, &b, scope); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.DB.Save(&b).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save branding"})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Update Default Branding", target, fmt.Sprintf("Nadir: %v, Watermark: %v", b.NadirPath != "", b.WatermarkPath != ""))
+
+	// Re-slice every project that inherits this default
+	go func(db *gorm.DB, r2 *s3.R2Service) {
+		var ids []string
+		query := db.Model(&models.Project{}).Where("id NOT IN (?)", db.Model(&models.Branding{}).Select("project_id").Where("project_id IS NOT NULL"))
+		if orgID != nil {
+			query = query.Where("user_id IN (?)", db.Model(&models.User{}).Select("id").Where("organization_id = ?", *orgID))
+		} else {
+			// Organizations with their own default are unaffected
+			orgDefaults := db.Model(&models.Branding{}).Select("organization_id").Where("project_id IS NULL AND organization_id IS NOT NULL")
+			query = query.Where("user_id NOT IN (?)", db.Model(&models.User{}).Select("id").Where("organization_id IN (?)", orgDefaults))
+		}
+		query.Pluck("id", &ids)
+		for _, id := range ids {
+			resliceProject(db, r2, id)
+		}
+	}(h.DB, h.R2)
+
+	return c.JSON(b)
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/policy"
+)
+
+type cohortRequest struct {
+	OrganizationID      *uint  `json:"organization_id"` // platform admins only, org-admins create in their own
+	Name                string `json:"name"`
+	Description         string `json:"description"`
+	PolicyID            *uint  `json:"policy_id"`
+	InstructorID        *uint  `json:"instructor_id"`
+	StartsAt            string `json:"starts_at"` // ISO strings, empty for none
+	ExpiresAt           string `json:"expires_at"`
+	LocksAt             string `json:"locks_at"`
+	RegistrationCodeIDs []uint `json:"registration_code_ids"` // codes to link, existing registrations follow
+	ApplyDates          bool   `json:"apply_dates"`           // move current members onto the shared dates
+}
+
+// apply copies the request onto a cohort, returning a validation message on failure
+func (r cohortRequest) apply(h *AdminHandler, c *fiber.Ctx, cohort *models.Cohort) string {
+	if r.Name == "" {
+		return "Cohort name is required"
+	}
+	orgID, ok := h.targetOrganization(c, r.OrganizationID)
+	if !ok {
+		return "Organization not found"
+	}
+	if !h.policyExists(r.PolicyID) {
+		return "Policy not found"
+	}
+	if r.InstructorID != nil {
+		var instructor models.User
+		if err := scoped(c, h.DB, "organization_id").First(&instructor, *r.InstructorID).Error; err != nil {
+			return "Instructor not found"
+		}
+		if orgID != nil && (instructor.OrganizationID == nil || *instructor.OrganizationID != *orgID) {
+			return "Instructor must belong to the cohort's organization"
+		}
+		if !auth.Can(&instructor, auth.PermViewCohortProjects) {
+			return "Instructor must have the instructor role"
+		}
+	}
+
+	dates := []*time.Time{nil, nil, nil}
+	for i, v := range []string{r.StartsAt, r.ExpiresAt, r.LocksAt} {
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "Dates must be ISO 8601 timestamps"
+		}
+		dates[i] = &t
+	}
+	if dates[1] != nil && dates[2] != nil && dates[2].Before(*dates[1]) {
+		return "Lockout date cannot be before the end of the creative phase"
+	}
+
+	cohort.OrganizationID = orgID
+	cohort.Name = r.Name
+	cohort.Description = r.Description
+	cohort.PolicyID = r.PolicyID
+	cohort.InstructorID = r.InstructorID
+	cohort.StartsAt, cohort.ExpiresAt, cohort.LocksAt = dates[0], dates[1], dates[2]
+	return ""
+}
+
+// cohortExists validates an optional cohort reference from a request
+func (h *AdminHandler) cohortExists(c *fiber.Ctx, id *uint) bool {
+	if id == nil {
+		return true
+	}
+	var count int64
+	scoped(c, h.DB.Model(&models.Cohort{}), "organization_id").Where("id = ?", *id).Count(&count)
+	return count > 0
+}
+
+// linkRegistrationCodes assigns codes to a cohort and brings along the users who
+// registered with them before the cohort existed. Codes and users of other
+// organizations, and users the caller does not manage, are left alone.
+func linkRegistrationCodes(c *fiber.Ctx, tx *gorm.DB, cohort models.Cohort, codeIDs []uint) {
+	if len(codeIDs) == 0 {
+		return
+	}
+	var codes []models.RegistrationCode
+	query := tx.Where("id IN ?", codeIDs)
+	if cohort.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *cohort.OrganizationID)
+	}
+	query.Find(&codes)
+	for _, code := range codes {
+		tx.Model(&code).Update("cohort_id", cohort.ID)
+		var members []models.User
+		query := tx.Where("reg_source = ? AND cohort_id IS NULL", "Code:"+code.Code)
+		if cohort.OrganizationID != nil {
+			query = query.Where("organization_id = ?", *cohort.OrganizationID)
+		}
+		query.Find(&members)
+		var ids []uint
+		for _, m := range manageableUsers(c, members) {
+			ids = append(ids, m.ID)
+		}
+		if len(ids) > 0 {
+			tx.Model(&models.User{}).Where("id IN ?", ids).Update("cohort_id", cohort.ID)
+		}
+	}
+}
+
+// applyCohortDates moves every member of a cohort the caller manages onto its shared dates
+func applyCohortDates(c *fiber.Ctx, tx *gorm.DB, cohort models.Cohort) {
+	var members []models.User
+	tx.Where("cohort_id = ?", cohort.ID).Find(&members)
+	members = manageableUsers(c, members)
+	for i := range members {
+		policy.ApplyCohort(&members[i], cohort)
+		tx.Model(&members[i]).Updates(map[string]interface{}{"expires_at": members[i].ExpiresAt, "locks_at": members[i].LocksAt})
+	}
+}
+
+func (h *AdminHandler) ListCohorts(c *fiber.Ctx) error {
+	type CohortResponse struct {
+		models.Cohort
+		MemberCount int64 `json:"member_count"`
+	}
+
+	var cohorts []models.Cohort
+	scoped(c, h.DB, "organization_id").Preload("Instructor").Order("created_at desc").Find(&cohorts)
+
+	res := make([]CohortResponse, len(cohorts))
+	for i, cohort := range cohorts {
+		res[i].Cohort = cohort
+		h.DB.Model(&models.User{}).Where("cohort_id = ?", cohort.ID).Count(&res[i].MemberCount)
+	}
+	return c.JSON(res)
+}
+
+func (h *AdminHandler) GetCohort(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").Preload("Instructor").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+
+	var codes []models.RegistrationCode
+	h.DB.Where("cohort_id = ?", cohort.ID).Find(&codes)
+	var invitations []models.Invitation
+	h.DB.Where("cohort_id = ?", cohort.ID).Find(&invitations)
+	var memberCount int64
+	h.DB.Model(&models.User{}).Where("cohort_id = ?", cohort.ID).Count(&memberCount)
+
+	return c.JSON(fiber.Map{
+		"cohort":             cohort,
+		"registration_codes": codes,
+		"invitations":        invitations,
+		"member_count":       memberCount,
+	})
+}
+
+func (h *AdminHandler) CreateCohort(c *fiber.Ctx) error {
+	var req cohortRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	var cohort models.Cohort
+	if msg := req.apply(h, c, &cohort); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&cohort).Error; err != nil {
+			return err
+		}
+		linkRegistrationCodes(c, tx, cohort, req.RegistrationCodeIDs)
+		if req.ApplyDates {
+			applyCohortDates(c, tx, cohort)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create cohort"})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Create Cohort", cohort.Name, cohort.Description)
+
+	return c.JSON(cohort)
+}
+
+func (h *AdminHandler) UpdateCohort(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+
+	var req cohortRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := req.apply(h, c, &cohort); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&cohort).Error; err != nil {
+			return err
+		}
+		linkRegistrationCodes(c, tx, cohort, req.RegistrationCodeIDs)
+		if req.ApplyDates {
+			applyCohortDates(c, tx, cohort)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update cohort"})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Update Cohort", cohort.Name, fmt.Sprintf("Dates applied to members: %v", req.ApplyDates))
+
+	return c.JSON(cohort)
+}
+
+func (h *AdminHandler) DeleteCohort(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+
+	// Members, codes and invitations stay, they just leave the cohort
+	h.DB.Transaction(func(tx *gorm.DB) error {
+		tx.Model(&models.User{}).Where("cohort_id = ?", cohort.ID).Update("cohort_id", nil)
+		tx.Model(&models.RegistrationCode{}).Where("cohort_id = ?", cohort.ID).Update("cohort_id", nil)
+		tx.Model(&models.Invitation{}).Where("cohort_id = ?", cohort.ID).Update("cohort_id", nil)
+		return tx.Delete(&cohort).Error
+	})
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Delete Cohort", cohort.Name, "")
+
+	return c.JSON(fiber.Map{"message": "Cohort deleted"})
+}
+
+func (h *AdminHandler) ListCohortMembers(c *fiber.Ctx) error {
+	var members []models.User
+	scoped(c, h.DB, "organization_id").Preload("Projects").Where("cohort_id = ?", c.Params("id")).Order("email").Find(&members)
+	for i := range members {
+		members[i].Phase = policy.Phase(&members[i], time.Now())
+	}
+	return c.JSON(members)
+}
+
+func (h *AdminHandler) ListCohortProjects(c *fiber.Ctx) error {
+	var projects []models.Project
+	scoped(c, h.DB, "users.organization_id").Preload("Scenes").Preload("User").
+		Joins("JOIN users ON users.id = projects.user_id AND users.deleted_at IS NULL").
+		Where("users.cohort_id = ?", c.Params("id")).
+		Order("projects.created_at desc").Find(&projects)
+	return c.JSON(projects)
+}
+
+func (h *AdminHandler) AddCohortMembers(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+
+	type Request struct {
+		UserIDs []uint `json:"user_ids"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil || len(req.UserIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	var users []models.User
+	query := h.DB.Where("id IN ?", req.UserIDs)
+	if cohort.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *cohort.OrganizationID)
+	}
+	query.Find(&users)
+	users = manageableUsers(c, users)
+	h.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range users {
+			users[i].CohortID = &cohort.ID
+			policy.ApplyCohort(&users[i], cohort)
+			tx.Model(&users[i]).Updates(map[string]interface{}{"cohort_id": cohort.ID, "expires_at": users[i].ExpiresAt, "locks_at": users[i].LocksAt})
+		}
+		return nil
+	})
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Add Cohort Members", cohort.Name, fmt.Sprintf("%d users", len(users)))
+
+	return c.JSON(fiber.Map{"message": "Members added", "count": len(users)})
+}
+
+func (h *AdminHandler) RemoveCohortMember(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+	var user models.User
+	if err := h.DB.Where("id = ? AND cohort_id = ?", c.Params("userID"), cohort.ID).First(&user).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User is not a member of this cohort"})
+	}
+	if !manageable(c, &user) {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot manage this account"})
+	}
+
+	// The member keeps their current dates
+	h.DB.Model(&user).Update("cohort_id", nil)
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Remove Cohort Member", cohort.Name, user.Email)
+
+	return c.JSON(fiber.Map{"message": "Member removed"})
+}
+
+// ExtendCohort gives every member of the cohort more creative time
+func (h *AdminHandler) ExtendCohort(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+
+	type Request struct {
+		Days int `json:"days"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.Days < 1 || req.Days > maxExtensionDays {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Extension must be between 1 and %d days", maxExtensionDays)})
+	}
+
+	now := time.Now()
+	// Only members ranked below the caller, an org-admin in the cohort cannot extend themselves
+	var members []models.User
+	h.DB.Where("cohort_id = ?", cohort.ID).Find(&members)
+	members = manageableUsers(c, members)
+
+	h.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range members {
+			policy.Extend(&members[i], policy.Resolve(tx, members[i].PolicyID), req.Days, now)
+			tx.Model(&members[i]).Updates(map[string]interface{}{"expires_at": members[i].ExpiresAt, "locks_at": members[i].LocksAt})
+		}
+		// Shared dates move too, so later registrations land on the extended schedule
+		if cohort.ExpiresAt != nil {
+			t := cohort.ExpiresAt.AddDate(0, 0, req.Days)
+			cohort.ExpiresAt = &t
+		}
+		if cohort.LocksAt != nil {
+			t := cohort.LocksAt.AddDate(0, 0, req.Days)
+			cohort.LocksAt = &t
+		}
+		return tx.Save(&cohort).Error
+	})
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Extend Cohort", cohort.Name, fmt.Sprintf("%d days for %d members", req.Days, len(members)))
+
+	return c.JSON(fiber.Map{"message": "Cohort extended", "count": len(members)})
+}
+
+// ToggleCohortActive deactivates or reactivates a cohort together with all its members
+func (h *AdminHandler) ToggleCohortActive(c *fiber.Ctx) error {
+	var cohort models.Cohort
+	if err := scoped(c, h.DB, "organization_id").First(&cohort, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cohort not found"})
+	}
+
+	cohort.IsActive = !cohort.IsActive
+
+	// Reactivating only brings back the members the deactivation took out, not
+	// those an admin had deactivated on their own
+	var members []models.User
+	query := h.DB.Where("cohort_id = ? AND is_admin = ?", cohort.ID, false)
+	if cohort.IsActive {
+		query = query.Where("cohort_disabled = ?", true)
+	} else {
+		query = query.Where("is_active = ?", true)
+	}
+	query.Find(&members)
+	members = manageableUsers(c, members)
+	ids := make([]uint, len(members))
+	for i, m := range members {
+		ids[i] = m.ID
+	}
+	h.DB.Transaction(func(tx *gorm.DB) error {
+		if len(ids) > 0 {
+			tx.Model(&models.User{}).Where("id IN ?", ids).Updates(map[string]interface{}{"is_active": cohort.IsActive, "cohort_disabled": !cohort.IsActive})
+		}
+		return tx.Save(&cohort).Error
+	})
+	if !cohort.IsActive {
+		for _, m := range members {
+			auth.RevokeUserSessions(h.DB, m.ID, auth.RevokedDeactivated)
+		}
+	}
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Toggle Cohort Active", cohort.Name, fmt.Sprintf("Active: %v, %d members", cohort.IsActive, len(members)))
+
+	return c.JSON(cohort)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+)
+
+// maxUnlockDays caps how long a project can be reopened in one go
+const maxUnlockDays = 30
+
+func (h *ProjectHandler) ListComments(c *fiber.Ctx) error {
+	var comments []models.Comment
+	h.DB.Preload("Author", func(db *gorm.DB) *gorm.DB { return db.Select("id", "full_name") }).Where("project_id = ?", currentProject(c).ID).Order("created_at").Find(&comments)
+	return c.JSON(comments)
+}
+
+func (h *ProjectHandler) CreateComment(c *fiber.Ctx) error {
+	project := currentProject(c)
+
+	type Request struct {
+		Body    string   `json:"body"`
+		SceneID string   `json:"scene_id"`
+		Yaw     *float64 `json:"yaw"` // optional view the comment points at
+		Pitch   *float64 `json:"pitch"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Comment cannot be empty"})
+	}
+	if req.SceneID != "" {
+		var count int64
+		h.DB.Model(&models.Scene{}).Where("id = ? AND project_id = ?", req.SceneID, project.ID).Count(&count)
+		if count == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Scene does not belong to this project"})
+		}
+	}
+
+	comment := models.Comment{
+		ProjectID: project.ID,
+		SceneID:   req.SceneID,
+		AuthorID:  c.Locals("user_id").(uint),
+		Body:      req.Body,
+		Yaw:       req.Yaw,
+		Pitch:     req.Pitch,
+	}
+	if err := h.DB.Create(&comment).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save comment"})
+	}
+	user := auth.CurrentUser(c)
+	comment.Author = &models.Author{ID: user.ID, FullName: user.FullName}
+
+	return c.JSON(comment)
+}
+
+func (h *ProjectHandler) DeleteComment(c *fiber.Ctx) error {
+	var comment models.Comment
+	if err := h.DB.Where("id = ? AND project_id = ?", c.Params("commentID"), currentProject(c).ID).First(&comment).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Comment not found"})
+	}
+
+	// Authors remove their own comments, staff can moderate any
+	user := auth.CurrentUser(c)
+	if comment.AuthorID != user.ID && !auth.Can(user, auth.PermEditAllProjects) {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	h.DB.Delete(&comment)
+	return c.JSON(fiber.Map{"message": "Comment deleted"})
+}
+
+// UnlockProject lets the owner edit the project again for a few days after their
+// creative phase ended, e.g. to act on an instructor's feedback
+func (h *ProjectHandler) UnlockProject(c *fiber.Ctx) error {
+	project := currentProject(c)
+
+	type Request struct {
+		Days   int    `json:"days"` // default 7
+		Reason string `json:"reason"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Days == 0 {
+		req.Days = 7
+	}
+	if req.Days < 1 || req.Days > maxUnlockDays {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Unlock must be between 1 and %d days", maxUnlockDays)})
+	}
+
+	userID := c.Locals("user_id").(uint)
+	until := time.Now().AddDate(0, 0, req.Days)
+	h.DB.Model(project).Updates(map[string]interface{}{"unlocked_until": until, "unlocked_by": userID})
+
+	logAudit(h.DB, userID, "Unlock Project", fmt.Sprintf("Project: %s", project.Name), fmt.Sprintf("Until %s. %s", until.Format("2006-01-02"), req.Reason))
+
+	return c.JSON(fiber.Map{"message": "Project unlocked", "unlocked_until": until})
+}
+
+func (h *ProjectHandler) LockProject(c *fiber.Ctx) error {
+	project := currentProject(c)
+	userID := c.Locals("user_id").(uint)
+
+	h.DB.Model(project).Updates(map[string]interface{}{"unlocked_until": nil, "unlocked_by": nil})
+	logAudit(h.DB, userID, "Lock Project", fmt.Sprintf("Project: %s", project.Name), "")
+
+	return c.JSON(fiber.Map{"message": "Project locked"})
+}
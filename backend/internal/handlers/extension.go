@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/mail"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/org"
+	"a360-platform/backend/internal/policy"
+)
+
+// maxExtensionDays caps a single request at one year
+const maxExtensionDays = 365
+
+func (h *AuthHandler) RequestExtension(c *fiber.Ctx) error {
+	user := auth.CurrentUser(c)
+
+	type Request struct {
+		Reason        string `json:"reason"`
+		RequestedDays int    `json:"requested_days"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Please tell us why you need more time"})
+	}
+	if req.RequestedDays < 1 || req.RequestedDays > maxExtensionDays {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Requested duration must be between 1 and %d days", maxExtensionDays)})
+	}
+	if user.IsAdmin {
+		return c.Status(400).JSON(fiber.Map{"error": "Admin accounts do not expire"})
+	}
+
+	var pending int64
+	h.DB.Model(&models.ExtensionRequest{}).Where("user_id = ? AND status = ?", user.ID, "pending").Count(&pending)
+	if pending > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "You already have a pending extension request"})
+	}
+
+	ext := models.ExtensionRequest{
+		UserID:        user.ID,
+		Reason:        req.Reason,
+		RequestedDays: req.RequestedDays,
+		Status:        "pending",
+	}
+	if err := h.DB.Create(&ext).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to file extension request"})
+	}
+
+	logAudit(h.DB, user.ID, "Request Extension", user.Email, fmt.Sprintf("%d days: %s", req.RequestedDays, req.Reason))
+
+	var admins []string
+	reviewers := h.DB.Where("is_admin = ?", true)
+	if user.OrganizationID != nil {
+		reviewers = reviewers.Or("role = ? AND organization_id = ?", auth.RoleOrgAdmin, *user.OrganizationID)
+	}
+	h.DB.Model(&models.User{}).Where("is_active = ?", true).Where(reviewers).Pluck("email", &admins)
+	_ = mail.SendExtensionRequested(org.Sender(h.DB, user.OrganizationID), admins, user.Email, req.Reason, req.RequestedDays)
+
+	return c.JSON(ext)
+}
+
+func (h *AuthHandler) ListExtensionRequests(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	var requests []models.ExtensionRequest
+	h.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&requests)
+	return c.JSON(requests)
+}
+
+func (h *AdminHandler) ListExtensionRequests(c *fiber.Ctx) error {
+	var requests []models.ExtensionRequest
+	query := membersOnly(c, h.DB, "user_id").Preload("User").Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Find(&requests)
+	return c.JSON(requests)
+}
+
+func (h *AdminHandler) ApproveExtensionRequest(c *fiber.Ctx) error {
+	type Request struct {
+		Days int    `json:"days"` // defaults to the requested duration
+		Note string `json:"note"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	ext, user, status, msg := h.pendingExtension(c)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	days := req.Days
+	if days == 0 {
+		days = ext.RequestedDays
+	}
+	if days < 1 || days > maxExtensionDays {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Granted duration must be between 1 and %d days", maxExtensionDays)})
+	}
+
+	now := time.Now()
+	adminID := c.Locals("user_id").(uint)
+	ext.Status = "approved"
+	ext.GrantedDays = days
+	ext.ReviewerID = &adminID
+	ext.ReviewNote = req.Note
+	ext.ReviewedAt = &now
+
+	// Claim the request first so two reviewers cannot both extend the membership
+	tx := h.DB.Begin()
+	res := tx.Model(&models.ExtensionRequest{}).Where("id = ? AND status = ?", ext.ID, "pending").Updates(map[string]interface{}{
+		"status": ext.Status, "granted_days": days, "reviewer_id": adminID, "review_note": req.Note, "reviewed_at": now,
+	})
+	if res.Error != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update request"})
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(409).JSON(fiber.Map{"error": "This request has already been reviewed"})
+	}
+	if err := tx.First(&user, user.ID).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to extend membership"})
+	}
+	policy.Extend(&user, policy.Resolve(h.DB, user.PolicyID), days, now)
+	if err := tx.Model(&user).Updates(map[string]interface{}{"expires_at": user.ExpiresAt, "locks_at": user.LocksAt}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to extend membership"})
+	}
+	tx.Commit()
+
+	h.logAdminAction(adminID, "Approve Extension", user.Email, fmt.Sprintf("%d days, creative phase until %s", days, user.ExpiresAt.Format("2006-01-02")))
+	_ = mail.SendExtensionDecision(org.Sender(h.DB, user.OrganizationID), user.Email, true, days, user.ExpiresAt, req.Note)
+
+	return c.JSON(ext)
+}
+
+func (h *AdminHandler) DenyExtensionRequest(c *fiber.Ctx) error {
+	type Request struct {
+		Note string `json:"note"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	ext, user, status, msg := h.pendingExtension(c)
+	if status != 0 {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	now := time.Now()
+	adminID := c.Locals("user_id").(uint)
+	ext.Status = "denied"
+	ext.ReviewerID = &adminID
+	ext.ReviewNote = req.Note
+	ext.ReviewedAt = &now
+	res := h.DB.Model(&models.ExtensionRequest{}).Where("id = ? AND status = ?", ext.ID, "pending").Updates(map[string]interface{}{
+		"status": ext.Status, "reviewer_id": adminID, "review_note": req.Note, "reviewed_at": now,
+	})
+	if res.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update request"})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "This request has already been reviewed"})
+	}
+
+	h.logAdminAction(adminID, "Deny Extension", user.Email, req.Note)
+	_ = mail.SendExtensionDecision(org.Sender(h.DB, user.OrganizationID), user.Email, false, 0, user.ExpiresAt, req.Note)
+
+	return c.JSON(ext)
+}
+
+// pendingExtension loads the request in :id and its user, or returns the error status and message
+func (h *AdminHandler) pendingExtension(c *fiber.Ctx) (models.ExtensionRequest, models.User, int, string) {
+	var ext models.ExtensionRequest
+	var user models.User
+	if err := h.DB.First(&ext, c.Params("id")).Error; err != nil {
+		return ext, user, 404, "Extension request not found"
+	}
+	if ext.Status != "pending" {
+		return ext, user, 409, "Extension request was already " + ext.Status
+	}
+	if err := scoped(c, h.DB, "organization_id").First(&user, ext.UserID).Error; err != nil {
+		return ext, user, 404, "User not found"
+	}
+	// Nobody reviews their own request, and org-admins only review members below them
+	if ext.UserID == c.Locals("user_id").(uint) {
+		return ext, user, 403, "You cannot review your own extension request"
+	}
+	if !manageable(c, &user) {
+		return ext, user, 403, "You cannot manage this account"
+	}
+	return ext, user, 0, ""
+}
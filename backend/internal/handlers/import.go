@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/pipeline"
+	"a360-platform/backend/internal/s3"
+)
+
+// ImportProject creates a project from a zip produced by cmd/slice, publishing the
+// pre-sliced scenes as they are instead of running the pipeline again
+func (h *ProjectHandler) ImportProject(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	user := auth.CurrentUser(c)
+
+	var projectCount int64
+	h.DB.Model(&models.Project{}).Where("user_id = ?", userID).Count(&projectCount)
+	if !auth.Can(user, auth.PermBypassLimits) && int(projectCount) >= user.ProjectLimit {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Project limit reached (%d/%d)", projectCount, user.ProjectLimit)})
+	}
+
+	file, err := c.FormFile("bundle")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "No bundle uploaded"})
+	}
+	f, err := file.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Failed to read bundle"})
+	}
+	defer f.Close()
+	zr, err := zip.NewReader(f, file.Size)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Bundle is not a zip archive"})
+	}
+
+	// Quota check on the unpacked size, before anything is written. Sizes come from
+	// the zip headers, so each one is bounded by what is left before they are summed.
+	quotaMB, _ := strconv.ParseInt(os.Getenv("STORAGE_QUOTA_MB"), 10, 64)
+	if quotaMB == 0 {
+		quotaMB = 500
+	}
+	remaining := quotaMB*1024*1024 - user.StorageUsed
+	var totalSize int64
+	for _, zf := range zr.File {
+		if remaining < 0 || zf.UncompressedSize64 > uint64(remaining-totalSize) {
+			return c.Status(403).JSON(fiber.Map{"error": "Storage quota exceeded"})
+		}
+		totalSize += int64(zf.UncompressedSize64)
+	}
+	if totalSize <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Bundle is empty"})
+	}
+
+	projectID := uuid.New().String()
+	// Stage outside ./uploads, the static root, until the assets are checked and the originals stripped
+	stagingDir, err := os.MkdirTemp("", "import-*")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to stage bundle"})
+	}
+	if err := extractZip(zr, stagingDir); err != nil {
+		os.RemoveAll(stagingDir)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid bundle: " + err.Error()})
+	}
+	bundle, err := pipeline.ReadBundle(filepath.Join(stagingDir, pipeline.BundleFile))
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid bundle: " + err.Error()})
+	}
+
+	var scenes []pipeline.BundleScene
+	for _, s := range bundle.Scenes {
+		if s.Error == "" {
+			scenes = append(scenes, s)
+		}
+	}
+	if len(scenes) == 0 {
+		os.RemoveAll(stagingDir)
+		return c.Status(400).JSON(fiber.Map{"error": "Bundle contains no sliced scenes"})
+	}
+
+	name := c.FormValue("name")
+	if name == "" {
+		name = "Imported tour"
+	}
+	isPublic := c.FormValue("is_public") == "true"
+	magicCode := ""
+	if isPublic {
+		magicCode = h.generateUniqueMagicCode()
+	}
+
+	// The check above is a fast path, this is the one that holds under concurrent uploads
+	if !reserveStorage(h.DB, userID, totalSize, quotaMB*1024*1024) {
+		os.RemoveAll(stagingDir)
+		return c.Status(403).JSON(fiber.Map{"error": "Storage quota exceeded"})
+	}
+
+	project := models.Project{
+		ID:              projectID,
+		UserID:          userID,
+		Name:            name,
+		IsPublic:        isPublic,
+		MagicCode:       magicCode,
+		Size:            totalSize,
+		Status:          "processing",
+		KeepGeolocation: c.FormValue("keep_geolocation") == "true",
+	}
+	h.DB.Create(&project)
+
+	sceneIDs := make([]string, len(scenes))
+	for i, s := range scenes {
+		sceneIDs[i] = uuid.New().String()
+		scenePath := fmt.Sprintf("uploads/%s/%s", projectID, sceneIDs[i])
+
+		var size int64
+		for _, rel := range s.Files() {
+			if info, err := os.Stat(filepath.Join(stagingDir, filepath.FromSlash(s.Dir), filepath.FromSlash(rel))); err == nil {
+				size += info.Size()
+			}
+		}
+
+		sceneName := s.Name
+		if sceneName == "" {
+			sceneName = fmt.Sprintf("Scene %d", i+1)
+		}
+		scene := models.Scene{
+			ID:           sceneIDs[i],
+			ProjectID:    projectID,
+			OriginalFile: s.OriginalFile(),
+			Name:         sceneName,
+			PanoPath:     scenePath,
+			Status:       "processing",
+			DisplayOrder: i,
+			Size:         size,
+		}
+		h.DB.Create(&scene)
+
+		if i == 0 {
+			h.DB.Model(&project).Updates(map[string]interface{}{"pano_path": scenePath, "cover_scene_id": scene.ID})
+		}
+	}
+
+	go importScenes(h.DB, h.R2, project, stagingDir, scenes, sceneIDs)
+
+	logAudit(h.DB, userID, "Import Project", fmt.Sprintf("Project: %s", project.Name), fmt.Sprintf("%d scenes from bundle created %s", len(scenes), bundle.CreatedAt.Format(time.RFC3339)))
+
+	return c.JSON(project)
+}
+
+// importScenes copies each bundle scene into place and publishes it like sliceScene
+// would. The project is marked as failed when none of its scenes could be imported.
+func importScenes(db *gorm.DB, r2 *s3.R2Service, project models.Project, stagingDir string, scenes []pipeline.BundleScene, sceneIDs []string) {
+	defer os.RemoveAll(stagingDir)
+
+	imported := 0
+	for i, s := range scenes {
+		if importScene(db, r2, project, stagingDir, s, sceneIDs[i]) {
+			imported++
+		}
+	}
+
+	if imported == 0 {
+		db.Model(&models.Project{}).Where("id = ?", project.ID).Update("status", "error")
+		return
+	}
+	updateProjectStatus(db, project.ID)
+}
+
+// importScene imports one bundle scene, holding a slicing slot like sliceScene does,
+// and reports whether it succeeded
+func importScene(db *gorm.DB, r2 *s3.R2Service, project models.Project, stagingDir string, s pipeline.BundleScene, sid string) (ok bool) {
+	slicingSemaphore <- struct{}{}
+	defer func() { <-slicingSemaphore }()
+	defer recoverSlicing(db, sid, project.ID)
+
+	sceneDir := fmt.Sprintf("./uploads/%s/%s", project.ID, sid)
+	updates := map[string]interface{}{"status": "ready", "processing_error": ""}
+	err := importSceneDir(filepath.Join(stagingDir, filepath.FromSlash(s.Dir)), sceneDir, s, project.KeepGeolocation)
+	if err != nil {
+		updates["status"] = "error"
+		updates["processing_error"] = err.Error()
+	} else {
+		sceneResultUpdates(updates, project.ID, sid, s)
+		if r2 != nil {
+			publishSceneDir(context.Background(), r2, fmt.Sprintf("%s/%s", project.ID, sid), sceneDir)
+			os.RemoveAll(sceneDir)
+		}
+	}
+
+	db.Model(&models.Scene{}).Where("id = ?", sid).Updates(updates)
+	return err == nil
+}
+
+// importSceneDir copies the files of the scene manifest into place, after checking each one
+// is the image type it claims to be. Anything else in the bundle directory is left behind.
+func importSceneDir(src, dst string, s pipeline.BundleScene, keepLocation bool) error {
+	files := s.Files()
+	for _, rel := range files {
+		file := filepath.Join(src, filepath.FromSlash(rel))
+		if !fileExists(file) {
+			return fmt.Errorf("bundle is missing %s/%s", s.Dir, rel)
+		}
+		if err := pipeline.CheckAsset(file); err != nil {
+			return fmt.Errorf("bundle scene %s: %w", s.Dir, err)
+		}
+	}
+
+	// The bundle may have been sliced without stripping, the server policy applies regardless
+	if err := pipeline.StripMetadata(filepath.Join(src, filepath.FromSlash(s.OriginalFile())), keepLocation); err != nil {
+		return err
+	}
+
+	for _, rel := range files {
+		if err := copyFile(filepath.Join(src, filepath.FromSlash(rel)), filepath.Join(dst, filepath.FromSlash(rel))); err != nil {
+			os.RemoveAll(dst)
+			return err
+		}
+	}
+	return nil
+}
+
+// extractZip unpacks zr below dir, refusing entries that would land outside it
+func extractZip(zr *zip.Reader, dir string) error {
+	for _, zf := range zr.File {
+		if !filepath.IsLocal(zf.Name) {
+			return fmt.Errorf("unsafe path %q", zf.Name)
+		}
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			return fmt.Errorf("unsupported entry %q", zf.Name)
+		}
+
+		dst := filepath.Join(dir, filepath.FromSlash(zf.Name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := extractZipFile(zf, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(zf *zip.File, dst string) error {
+	src, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Never write more than the header declared, the quota check relied on it
+	n, err := io.Copy(out, io.LimitReader(src, int64(zf.UncompressedSize64)+1))
+	if err != nil {
+		return err
+	}
+	if n > int64(zf.UncompressedSize64) {
+		return errors.New("entry larger than declared: " + zf.Name)
+	}
+	return nil
+}
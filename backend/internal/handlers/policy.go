@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+)
+
+type policyRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	CreativeDays int    `json:"creative_days"`
+	ViewOnlyDays int    `json:"view_only_days"`
+	IsDefault    bool   `json:"is_default"`
+}
+
+func (r policyRequest) validate() string {
+	if r.Name == "" {
+		return "Policy name is required"
+	}
+	if r.CreativeDays < 1 || r.ViewOnlyDays < 0 {
+		return "Creative phase must be at least 1 day and view-only phase cannot be negative"
+	}
+	return ""
+}
+
+func (h *AdminHandler) ListPolicies(c *fiber.Ctx) error {
+	var policies []models.MembershipPolicy
+	h.DB.Order("name").Find(&policies)
+	return c.JSON(policies)
+}
+
+func (h *AdminHandler) CreatePolicy(c *fiber.Ctx) error {
+	var req policyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	p := models.MembershipPolicy{
+		Name:         req.Name,
+		Description:  req.Description,
+		CreativeDays: req.CreativeDays,
+		ViewOnlyDays: req.ViewOnlyDays,
+		IsDefault:    req.IsDefault,
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if p.IsDefault {
+			tx.Model(&models.MembershipPolicy{}).Where("is_default = ?", true).Update("is_default", false)
+		}
+		return tx.Create(&p).Error
+	})
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Policy name already exists"})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Create Policy", p.Name, fmt.Sprintf("Creative: %d days, View-only: %d days, Default: %v", p.CreativeDays, p.ViewOnlyDays, p.IsDefault))
+
+	return c.JSON(p)
+}
+
+func (h *AdminHandler) UpdatePolicy(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var p models.MembershipPolicy
+	if err := h.DB.First(&p, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Policy not found"})
+	}
+
+	var req policyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	// Users keep the dates they registered with; only new registrations pick up changes
+	p.Name = req.Name
+	p.Description = req.Description
+	p.CreativeDays = req.CreativeDays
+	p.ViewOnlyDays = req.ViewOnlyDays
+	p.IsDefault = req.IsDefault
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if p.IsDefault {
+			tx.Model(&models.MembershipPolicy{}).Where("is_default = ? AND id <> ?", true, p.ID).Update("is_default", false)
+		}
+		return tx.Save(&p).Error
+	})
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Policy name already exists"})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Update Policy", p.Name, fmt.Sprintf("Creative: %d days, View-only: %d days, Default: %v", p.CreativeDays, p.ViewOnlyDays, p.IsDefault))
+
+	return c.JSON(p)
+}
+
+func (h *AdminHandler) DeletePolicy(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var p models.MembershipPolicy
+	if err := h.DB.First(&p, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Policy not found"})
+	}
+
+	var codes, invitations, cohorts int64
+	h.DB.Model(&models.RegistrationCode{}).Where("policy_id = ?", p.ID).Count(&codes)
+	h.DB.Model(&models.Invitation{}).Where("policy_id = ? AND is_used = ?", p.ID, false).Count(&invitations)
+	h.DB.Model(&models.Cohort{}).Where("policy_id = ?", p.ID).Count(&cohorts)
+	if codes > 0 || invitations > 0 || cohorts > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot delete a policy assigned to registration codes, pending invitations or cohorts"})
+	}
+
+	// Members keep their dates, they just no longer reference the policy
+	h.DB.Transaction(func(tx *gorm.DB) error {
+		tx.Model(&models.User{}).Where("policy_id = ?", p.ID).Update("policy_id", nil)
+		tx.Model(&models.Invitation{}).Where("policy_id = ?", p.ID).Update("policy_id", nil)
+		tx.Unscoped().Model(&models.Cohort{}).Where("policy_id = ?", p.ID).Update("policy_id", nil)
+		return tx.Delete(&p).Error
+	})
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Delete Policy", p.Name, "")
+
+	return c.JSON(fiber.Map{"message": "Policy deleted"})
+}
+
+// policyExists validates an optional policy reference from a request
+func (h *AdminHandler) policyExists(id *uint) bool {
+	if id == nil {
+		return true
+	}
+	var count int64
+	h.DB.Model(&models.MembershipPolicy{}).Where("id = ?", *id).Count(&count)
+	return count > 0
+}
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,8 +17,10 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"a360-platform/backend/internal/auth"
 	"a360-platform/backend/internal/models"
 	"a360-platform/backend/internal/pipeline"
+	"a360-platform/backend/internal/policy"
 	"a360-platform/backend/internal/s3"
 	"a360-platform/backend/internal/utils"
 )
@@ -27,6 +33,9 @@ type ProjectHandler struct {
 // Limit concurrent slicing to prevent OOM across all requests
 var slicingSemaphore = make(chan struct{}, 2)
 
+// Video transcodes run for minutes, so they queue separately and never hold up panorama slicing
+var transcodeSemaphore = make(chan struct{}, 1)
+
 // View throttling in-memory cache: [IP + ProjectID] -> lastViewTime
 var viewCache sync.Map
 
@@ -60,20 +69,12 @@ func (h *ProjectHandler) UploadPano(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
 
 	// Get user to check quota
-	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "User not found"})
-	}
-
-	// Check creative phase expiry
-	if !user.IsAdmin && time.Now().After(user.ExpiresAt) {
-		return c.Status(403).JSON(fiber.Map{"error": "Creative Phase expired. Please contact A360 Workshop Team to extend your license."})
-	}
+	user := auth.CurrentUser(c)
 
 	// Check project limit
 	var projectCount int64
 	h.DB.Model(&models.Project{}).Where("user_id = ?", userID).Count(&projectCount)
-	if !user.IsAdmin && int(projectCount) >= user.ProjectLimit {
+	if !auth.Can(user, auth.PermBypassLimits) && int(projectCount) >= user.ProjectLimit {
 		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Project limit reached (%d/%d)", projectCount, user.ProjectLimit)})
 	}
 
@@ -82,12 +83,36 @@ func (h *ProjectHandler) UploadPano(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Failed to parse form"})
 	}
 
-	files := form.File["panos[]"]
-	if len(files) == 0 {
-		// Fallback for single file
-		singleFile, err := c.FormFile("pano")
-		if err == nil {
-			files = append(files, singleFile)
+	layout := c.FormValue("layout", pipeline.LayoutEquirect)
+	if !pipeline.IsLayout(layout) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid layout"})
+	}
+
+	// Every scene is one file, except six separate cube faces which make a single scene
+	var files []*multipart.FileHeader
+	var sceneFiles [][]*multipart.FileHeader
+	if layout == pipeline.LayoutFaces {
+		var faces []*multipart.FileHeader
+		for _, name := range pipeline.FaceNames {
+			face, err := c.FormFile("face_" + name)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Missing cube face " + name})
+			}
+			faces = append(faces, face)
+		}
+		files = faces
+		sceneFiles = append(sceneFiles, faces)
+	} else {
+		files = form.File["panos[]"]
+		if len(files) == 0 {
+			// Fallback for single file
+			singleFile, err := c.FormFile("pano")
+			if err == nil {
+				files = append(files, singleFile)
+			}
+		}
+		for _, file := range files {
+			sceneFiles = append(sceneFiles, []*multipart.FileHeader{file})
 		}
 	}
 
@@ -109,6 +134,68 @@ func (h *ProjectHandler) UploadPano(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "Storage quota exceeded"})
 	}
 
+	stereoMode := c.FormValue("stereo_mode", pipeline.StereoAuto)
+	if !pipeline.IsStereoMode(stereoMode) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid stereo mode"})
+	}
+	keepGeolocation := c.FormValue("keep_geolocation") == "true"
+	opts := pipeline.Options{
+		Stereo:            stereoMode,
+		AutoLevel:         true,
+		StripMetadata:     true,
+		KeepLocation:      keepGeolocation,
+		FaceProfiles:      encodingProfiles("FACE_FORMATS"),
+		ThumbnailProfiles: encodingProfiles("THUMBNAIL_FORMATS"),
+	}
+
+	// Partial and cylindrical panoramas
+	opts.Projection = c.FormValue("projection")
+	switch opts.Projection {
+	case "", pipeline.ProjectionEquirect, pipeline.ProjectionCylindrical, pipeline.ProjectionDualFisheye:
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid projection"})
+	}
+	opts.HFov, _ = strconv.ParseFloat(c.FormValue("h_fov"), 64)
+	opts.VFov, _ = strconv.ParseFloat(c.FormValue("v_fov"), 64)
+	if opts.HFov < 0 || opts.HFov > 360 || opts.VFov < 0 || opts.VFov > 180 {
+		return c.Status(400).JSON(fiber.Map{"error": "Field of view must be within 360x180 degrees"})
+	}
+
+	// Dual-fisheye lens parameters, lenses as [{"x":0.5,"y":0.5,"radius":0.5}, {...}]
+	if fov := c.FormValue("fisheye_fov"); fov != "" || c.FormValue("fisheye_lenses") != "" {
+		fisheye := &pipeline.Fisheye{}
+		fisheye.FOV, _ = strconv.ParseFloat(fov, 64)
+		if fisheye.FOV < 0 || fisheye.FOV > 360 {
+			return c.Status(400).JSON(fiber.Map{"error": "Fisheye field of view must be within 360 degrees"})
+		}
+		if lenses := c.FormValue("fisheye_lenses"); lenses != "" {
+			if err := json.Unmarshal([]byte(lenses), &fisheye.Lenses); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid fisheye lenses"})
+			}
+		}
+		opts.Fisheye = fisheye
+	}
+	// Tone mapping for .hdr/.exr uploads
+	opts.ToneMap = c.FormValue("tone_map")
+	if !pipeline.IsToneMap(opts.ToneMap) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid tone mapping operator"})
+	}
+	opts.Exposure, _ = strconv.ParseFloat(c.FormValue("exposure"), 64)
+	if math.Abs(opts.Exposure) > 10 {
+		return c.Status(400).JSON(fiber.Map{"error": "Exposure must be within ±10 stops"})
+	}
+	if fill := c.FormValue("fill_color"); fill != "" {
+		if _, err := pipeline.ParseHexColor(fill); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid fill color, expected #rrggbb"})
+		}
+		opts.Fill = fill
+	}
+
+	// The check above is a fast path, this is the one that holds under concurrent uploads
+	if !reserveStorage(h.DB, userID, totalSize, quotaMB*1024*1024) {
+		return c.Status(403).JSON(fiber.Map{"error": "Storage quota exceeded"})
+	}
+
 	projectID := uuid.New().String()
 	isPublic := c.FormValue("is_public") == "true"
 	magicCode := ""
@@ -118,163 +205,173 @@ func (h *ProjectHandler) UploadPano(c *fiber.Ctx) error {
 
 	// Create Project record
 	project := models.Project{
-		ID:        projectID,
-		UserID:    userID,
-		Name:      c.FormValue("name"),
-		IsPublic:  isPublic,
-		MagicCode: magicCode,
-		Size:      totalSize,
-		Status:    "processing",
+		ID:              projectID,
+		UserID:          userID,
+		Name:            c.FormValue("name"),
+		IsPublic:        isPublic,
+		MagicCode:       magicCode,
+		Size:            totalSize,
+		Status:          "processing",
+		KeepGeolocation: keepGeolocation,
 	}
 	h.DB.Create(&project)
 
-	// Update user storage
-	user.StorageUsed += totalSize
-	h.DB.Save(&user)
-
 	// Process each scene
-	for i, file := range files {
+	for i, group := range sceneFiles {
 		sceneID := uuid.New().String()
 		scenePath := fmt.Sprintf("uploads/%s/%s", projectID, sceneID)
 		uploadPath := fmt.Sprintf("./%s", scenePath)
 		os.MkdirAll(uploadPath, 0755)
 
-		filePath := filepath.Join(uploadPath, "original.jpg")
-		if err := c.SaveFile(file, filePath); err != nil {
+		// Cube layouts are kept aside until they are assembled into original.jpg,
+		// HDR originals keep their format so they can be tone mapped again
+		originalFile := "original.jpg"
+		if layout == pipeline.LayoutEquirect && pipeline.IsHDRFile(group[0].Filename) {
+			originalFile = "original" + strings.ToLower(filepath.Ext(group[0].Filename))
+		}
+		filePath := filepath.Join(uploadPath, originalFile)
+		var sources []string
+		var size int64
+		saved := true
+		isVideo := layout == pipeline.LayoutEquirect && pipeline.IsVideoFile(group[0].Filename)
+		for j, file := range group {
+			dst := filePath
+			if isVideo {
+				// The poster frame extracted from the video becomes original.jpg
+				dst = filepath.Join(uploadPath, "video"+strings.ToLower(filepath.Ext(file.Filename)))
+			} else if layout != pipeline.LayoutEquirect {
+				os.MkdirAll(filepath.Join(uploadPath, "source"), 0755)
+				dst = filepath.Join(uploadPath, "source", fmt.Sprintf("%d%s", j, filepath.Ext(file.Filename)))
+			}
+			if err := c.SaveFile(file, dst); err != nil {
+				saved = false
+				break
+			}
+			sources = append(sources, dst)
+			size += file.Size
+		}
+		if !saved {
+			os.RemoveAll(uploadPath)
 			continue
 		}
 
+		kind := "image"
+		if isVideo {
+			kind = "video"
+		}
 		scene := models.Scene{
 			ID:           sceneID,
 			ProjectID:    projectID,
+			Kind:         kind,
+			OriginalFile: originalFile,
 			Name:         fmt.Sprintf("Scene %d", i+1),
 			PanoPath:     scenePath,
 			Status:       "processing",
 			DisplayOrder: i,
-			Size:         file.Size,
+			Size:         size,
 		}
 		h.DB.Create(&scene)
 
-		// Set the first scene as the project's PanoPath for backward compatibility/thumbnail
+		// Set the first scene as the project's cover (PanoPath) for backward compatibility/thumbnail
 		if i == 0 {
-			h.DB.Model(&project).Update("pano_path", scenePath)
+			h.DB.Model(&project).Updates(map[string]interface{}{"pano_path": scenePath, "cover_scene_id": sceneID})
 		}
 
 		// Async Slice Pano
-		go func(sid, pid, fpath string, db *gorm.DB, r2 *s3.R2Service) {
-			slicingSemaphore <- struct{}{}
-			defer func() { <-slicingSemaphore }()
-
-			ctx := context.Background()
-			cubeDir := filepath.Join(filepath.Dir(fpath), "cubemap")
-			facePaths, err := pipeline.SlicePano(fpath, cubeDir)
-
-			if err == nil && r2 != nil {
-				// Upload original
-				r2.UploadFile(ctx, fmt.Sprintf("%s/%s/original.jpg", pid, sid), fpath, "image/jpeg")
-				// Upload faces
-				for _, fp := range facePaths {
-					r2.UploadFile(ctx, fmt.Sprintf("%s/%s/cubemap/%s", pid, sid, filepath.Base(fp)), fp, "image/jpeg")
-				}
-				// Upload thumbnail
-				thumbPath := filepath.Join(filepath.Dir(cubeDir), "thumbnail.jpg")
-				r2.UploadFile(ctx, fmt.Sprintf("%s/%s/thumbnail.jpg", pid, sid), thumbPath, "image/jpeg")
-
-				// Cleanup local project dir
-				os.RemoveAll(filepath.Dir(fpath))
-			}
-
-			status := "ready"
-			if err != nil {
-				status = "error"
-			}
+		if isVideo {
+			go processVideoScene(h.DB, h.R2, sceneID, projectID, sources[0], filePath, opts)
+		} else if layout != pipeline.LayoutEquirect {
+			go assembleScene(h.DB, h.R2, sceneID, projectID, sources, layout, filePath, opts)
+		} else {
+			go sliceScene(h.DB, h.R2, sceneID, projectID, filePath, opts)
+		}
+	}
 
-			db.Model(&models.Scene{}).Where("id = ?", sid).Update("status", status)
+	return c.JSON(project)
+}
 
-			// Update project status to ready if all scenes are ready or error
-			var unfinished int64
-			db.Model(&models.Scene{}).Where("project_id = ? AND status = ?", pid, "processing").Count(&unfinished)
-			if unfinished == 0 {
-				db.Model(&models.Project{}).Where("id = ?", pid).Update("status", "ready")
-			}
-		}(sceneID, projectID, filePath, h.DB, h.R2)
+// redactLocation hides scene GPS from public responses unless the owner opted in
+func redactLocation(project *models.Project) {
+	if project.KeepGeolocation {
+		return
 	}
+	for i := range project.Scenes {
+		project.Scenes[i].Latitude = nil
+		project.Scenes[i].Longitude = nil
+		project.Scenes[i].Altitude = nil
+	}
+}
 
-	return c.JSON(project)
+// ownerCanShare reports whether a tour's owner is still within their viewing period,
+// public tours go offline when the owner's membership locks. Requires User preloaded.
+func ownerCanShare(project *models.Project) bool {
+	return project.User == nil || policy.Phase(project.User, time.Now()) != policy.PhaseLocked
 }
 
+// GetProjects lists the caller's own projects; ?scope=cohort lists the projects of
+// students in cohorts the caller instructs and ?scope=all every project the caller
+// may oversee: the whole platform for admins, their organization for org-admins
 func (h *ProjectHandler) GetProjects(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
 	var projects []models.Project
 
-	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "User not found"})
-	}
+	user := auth.CurrentUser(c)
+	query := h.DB.Preload("Scenes").Preload("User").Order("projects.created_at desc")
 
-	if user.IsAdmin {
-		// Admin sees all public and active tours across the platform (Global Archives)
-		h.DB.Preload("Scenes").Preload("User").Where("is_public = ? AND is_active = ?", true, true).Order("created_at desc").Find(&projects)
-	} else {
-		h.DB.Preload("Scenes").Preload("User").Where("user_id = ?", userID).Order("created_at desc").Find(&projects)
+	switch c.Query("scope") {
+	case "all":
+		switch {
+		case auth.Can(user, auth.PermViewAllProjects):
+			if org := c.Query("organization_id"); org != "" {
+				query = query.Joins("JOIN users ON users.id = projects.user_id AND users.deleted_at IS NULL").
+					Where("users.organization_id = ?", org)
+			}
+		case auth.Can(user, auth.PermViewOrgProjects):
+			query = query.Joins("JOIN users ON users.id = projects.user_id AND users.deleted_at IS NULL").
+				Where("users.organization_id = ?", *user.OrganizationID)
+		default:
+			return c.Status(403).JSON(fiber.Map{"error": "You do not have permission to do this"})
+		}
+	case "cohort":
+		if !auth.Can(user, auth.PermViewCohortProjects) {
+			return c.Status(403).JSON(fiber.Map{"error": "You do not have permission to do this"})
+		}
+		query = query.Joins("JOIN users ON users.id = projects.user_id AND users.deleted_at IS NULL").
+			Joins("JOIN cohorts ON cohorts.id = users.cohort_id AND cohorts.deleted_at IS NULL").
+			Where("cohorts.instructor_id = ?", userID).
+			Where("cohorts.organization_id IS NOT DISTINCT FROM users.organization_id")
+	default:
+		query = query.Where("user_id = ?", userID)
 	}
+
+	query.Find(&projects)
 	return c.JSON(projects)
 }
 
 func (h *ProjectHandler) GetProject(c *fiber.Ctx) error {
-	id := c.Params("id")
-	userID := c.Locals("user_id").(uint)
-
-	var user models.User
-	h.DB.First(&user, userID)
-
 	var project models.Project
 	// Preload both project-level hotspots (legacy) and scene-level hotspots (multi-scene)
-	if err := h.DB.Preload("Hotspots").Preload("Scenes.Hotspots").Preload("User").Where("id = ?", id).First(&project).Error; err != nil {
+	if err := h.DB.Preload("Hotspots").Preload("Scenes.Hotspots").Preload("User").Where("id = ?", currentProject(c).ID).First(&project).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Project not found"})
 	}
 
-	if !user.IsAdmin && project.UserID != userID {
-		if !project.IsPublic || !project.IsActive {
-			return c.Status(403).JSON(fiber.Map{"error": "Unauthorized or Tour Inactive"})
-		}
+	// Visitors of a public tour get the same view as the public tour API
+	if c.Locals("project_relation") == relationPublic {
+		redactLocation(&project)
 	}
 
 	return c.JSON(project)
 }
+
 func (h *ProjectHandler) DeleteProject(c *fiber.Ctx) error {
 	id := c.Params("id")
-	userID := c.Locals("user_id").(uint)
-
-	var project models.Project
-	if err := h.DB.Where("id = ?", id).First(&project).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Project not found"})
-	}
-
-	// Permission check: Admin can delete any, user can delete own
-	var user models.User
-	h.DB.First(&user, userID)
-	if !user.IsAdmin && project.UserID != userID {
-		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
-	}
-
-	// Double check creative phase (Phase 1)
-	if !user.IsAdmin && time.Now().After(user.ExpiresAt) {
-		return c.Status(403).JSON(fiber.Map{"error": "Creative Phase expired. Only View-Only access is allowed. Contact A360 Workshop Team for extensions."})
-	}
+	project := *currentProject(c)
 
 	// Delete record
 	h.DB.Delete(&project)
 
 	// Update user storage
-	var projUser models.User
-	if err := h.DB.First(&projUser, project.UserID).Error; err == nil {
-		projUser.StorageUsed -= project.Size
-		if projUser.StorageUsed < 0 {
-			projUser.StorageUsed = 0
-		}
-		h.DB.Save(&projUser)
-	}
+	releaseStorage(h.DB, project.UserID, project.Size)
 
 	// Clean up files
 	ctx := context.Background()
@@ -283,34 +380,20 @@ func (h *ProjectHandler) DeleteProject(c *fiber.Ctx) error {
 	} else {
 		os.RemoveAll(project.PanoPath)
 	}
+	os.RemoveAll(filepath.Join("private", id))
 
 	return c.JSON(fiber.Map{"message": "Project deleted"})
 }
 
 func (h *ProjectHandler) UpdateProject(c *fiber.Ctx) error {
-	id := c.Params("id")
-	userID := c.Locals("user_id").(uint)
-
-	var project models.Project
-	if err := h.DB.Where("id = ?", id).First(&project).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Project not found"})
-	}
-
-	var user models.User
-	h.DB.First(&user, userID)
-	if !user.IsAdmin && project.UserID != userID {
-		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
-	}
-
-	// Double check creative phase (Phase 1)
-	if !user.IsAdmin && time.Now().After(user.ExpiresAt) {
-		return c.Status(403).JSON(fiber.Map{"error": "Creative Phase expired. Only View-Only access is allowed. Contact A360 Workshop Team for extensions."})
-	}
+	project := *currentProject(c)
 
 	type UpdateRequest struct {
-		Name     string `json:"name"`
-		IsPublic bool   `json:"is_public"`
-		IsActive bool   `json:"is_active"`
+		Name            string `json:"name"`
+		IsPublic        bool   `json:"is_public"`
+		IsActive        bool   `json:"is_active"`
+		KeepGeolocation bool   `json:"keep_geolocation"`
+		CoverSceneID    string `json:"cover_scene_id"`
 	}
 
 	var req UpdateRequest
@@ -328,33 +411,32 @@ func (h *ProjectHandler) UpdateProject(c *fiber.Ctx) error {
 	project.IsPublic = req.IsPublic
 	project.IsActive = req.IsActive
 
+	// The cover scene's thumbnail represents the project everywhere PanoPath is used
+	if req.CoverSceneID != "" && req.CoverSceneID != project.CoverSceneID {
+		var cover models.Scene
+		if err := h.DB.Where("id = ? AND project_id = ?", req.CoverSceneID, project.ID).First(&cover).Error; err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Cover scene does not belong to this project"})
+		}
+		project.CoverSceneID = cover.ID
+		project.PanoPath = cover.PanoPath
+	}
+
+	// Originals published while geolocation was kept still carry GPS
+	restrip := project.KeepGeolocation && !req.KeepGeolocation
+	project.KeepGeolocation = req.KeepGeolocation
+
 	h.DB.Save(&project)
 
+	if restrip {
+		go stripProjectOriginals(h.DB, h.R2, project.ID)
+	}
+
 	return c.JSON(project)
 }
 
 func (h *ProjectHandler) SaveHotspots(c *fiber.Ctx) error {
 	sceneID := c.Params("sceneID")
-	userID := c.Locals("user_id").(uint)
-
-	var scene models.Scene
-	if err := h.DB.Where("id = ?", sceneID).First(&scene).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Scene not found"})
-	}
-
-	var project models.Project
-	h.DB.Where("id = ?", scene.ProjectID).First(&project)
-
-	var user models.User
-	h.DB.First(&user, userID)
-
-	if !user.IsAdmin && project.UserID != userID {
-		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
-	}
-
-	if !user.IsAdmin && time.Now().After(user.ExpiresAt) {
-		return c.Status(403).JSON(fiber.Map{"error": "Creative Phase expired."})
-	}
+	scene := currentScene(c)
 
 	var req []models.Hotspot
 	if err := c.BodyParser(&req); err != nil {
@@ -394,19 +476,6 @@ func (h *ProjectHandler) SaveHotspots(c *fiber.Ctx) error {
 
 func (h *ProjectHandler) SaveProjectHotspots(c *fiber.Ctx) error {
 	id := c.Params("id")
-	userID := c.Locals("user_id").(uint)
-
-	var project models.Project
-	if err := h.DB.Where("id = ?", id).First(&project).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Project not found"})
-	}
-
-	var user models.User
-	h.DB.First(&user, userID)
-
-	if !user.IsAdmin && project.UserID != userID {
-		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
-	}
 
 	var req []models.Hotspot
 	if err := c.BodyParser(&req); err != nil {
@@ -450,57 +519,199 @@ func (h *ProjectHandler) GetProjectByMagicCode(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Public tour not found or inactive"})
 	}
 
+	if !ownerCanShare(&project) {
+		return c.Status(404).JSON(fiber.Map{"error": "Public tour not found or inactive"})
+	}
+
 	// Increment view count (throttled)
 	if h.shouldIncrementView(c.IP(), project.ID) {
 		h.DB.Model(&project).UpdateColumn("views", gorm.Expr("views + 1"))
 	}
 
+	redactLocation(&project)
 	return c.JSON(project)
 }
 
 func (h *ProjectHandler) UpdateScene(c *fiber.Ctx) error {
-	sceneID := c.Params("sceneID")
-	userID := c.Locals("user_id").(uint)
+	scene := *currentScene(c)
+	project := *currentProject(c)
 
-	// Permission check
-	var scene models.Scene
-	if err := h.DB.Where("id = ?", sceneID).First(&scene).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Scene not found"})
+	type UpdateRequest struct {
+		Name         string   `json:"name"`
+		InitialYaw   *float64 `json:"initial_yaw"`
+		InitialPitch *float64 `json:"initial_pitch"`
 	}
 
-	var project models.Project
-	h.DB.Where("id = ?", scene.ProjectID).First(&project)
+	var req UpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
 
-	var user models.User
-	h.DB.First(&user, userID)
+	scene.Name = req.Name
 
-	if !user.IsAdmin && project.UserID != userID {
-		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	// Moving the initial view also moves thumbnails that follow it
+	reslice := false
+	if req.InitialYaw != nil || req.InitialPitch != nil {
+		if req.InitialYaw != nil {
+			scene.InitialYaw = *req.InitialYaw
+		}
+		if req.InitialPitch != nil {
+			scene.InitialPitch = math.Max(-90, math.Min(90, *req.InitialPitch))
+		}
+		reslice = scene.ThumbnailYaw == nil && scene.Status != "processing"
 	}
+	h.DB.Save(&scene)
 
-	type UpdateRequest struct {
-		Name string `json:"name"`
+	if reslice {
+		go resliceScene(h.DB, h.R2, scene, sceneOptions(scene, project))
 	}
 
-	var req UpdateRequest
+	return c.JSON(scene)
+}
+
+func (h *ProjectHandler) UpdateSceneThumbnail(c *fiber.Ctx) error {
+	scene := *currentScene(c)
+	project := *currentProject(c)
+
+	if scene.Status == "processing" {
+		return c.Status(409).JSON(fiber.Map{"error": "Scene is still processing"})
+	}
+
+	type ThumbnailRequest struct {
+		Yaw            float64 `json:"yaw"`
+		Pitch          float64 `json:"pitch"`
+		UseInitialView bool    `json:"use_initial_view"`
+	}
+	var req ThumbnailRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if math.Abs(req.Pitch) > 90 {
+		return c.Status(400).JSON(fiber.Map{"error": "Pitch must be between -90 and 90"})
+	}
 
-	scene.Name = req.Name
+	if req.UseInitialView {
+		scene.ThumbnailYaw, scene.ThumbnailPitch = nil, nil
+	} else {
+		scene.ThumbnailYaw, scene.ThumbnailPitch = &req.Yaw, &req.Pitch
+	}
 	h.DB.Save(&scene)
 
+	go resliceScene(h.DB, h.R2, scene, sceneOptions(scene, project))
+
 	return c.JSON(scene)
 }
-func (h *ProjectHandler) UploadMedia(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(uint)
 
-	// Get user to check quota/permissions
-	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "User not found"})
+func (h *ProjectHandler) UpdateSceneToneMapping(c *fiber.Ctx) error {
+	scene := *currentScene(c)
+	project := *currentProject(c)
+
+	if !pipeline.IsHDRFile(sceneOriginalFile(scene)) {
+		return c.Status(400).JSON(fiber.Map{"error": "Tone mapping only applies to HDR scenes"})
+	}
+
+	if scene.Status == "processing" {
+		return c.Status(409).JSON(fiber.Map{"error": "Scene is still processing"})
+	}
+
+	type ToneMappingRequest struct {
+		Operator string  `json:"operator"`
+		Exposure float64 `json:"exposure"`
+	}
+	var req ToneMappingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Operator == "" {
+		req.Operator = pipeline.ToneMapReinhard
+	}
+	if !pipeline.IsToneMap(req.Operator) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid tone mapping operator"})
+	}
+	if math.Abs(req.Exposure) > 10 {
+		return c.Status(400).JSON(fiber.Map{"error": "Exposure must be within ±10 stops"})
+	}
+
+	scene.ToneMap = req.Operator
+	scene.Exposure = req.Exposure
+	h.DB.Save(&scene)
+
+	opts := sceneOptions(scene, project)
+	opts.ToneMap = req.Operator
+	opts.Exposure = req.Exposure
+	go resliceScene(h.DB, h.R2, scene, opts)
+
+	return c.JSON(scene)
+}
+
+func (h *ProjectHandler) UpdateSceneOrientation(c *fiber.Ctx) error {
+	scene := *currentScene(c)
+	project := *currentProject(c)
+
+	if scene.Status == "processing" {
+		return c.Status(409).JSON(fiber.Map{"error": "Scene is still processing"})
+	}
+
+	var req pipeline.Orientation
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if math.Abs(req.Pitch) > 90 || math.Abs(req.Roll) > 180 || math.Abs(req.Yaw) > 180 {
+		return c.Status(400).JSON(fiber.Map{"error": "Orientation out of range"})
+	}
+
+	from := pipeline.Orientation{Yaw: scene.CorrectionYaw, Pitch: scene.CorrectionPitch, Roll: scene.CorrectionRoll}
+
+	// Move hotspots and blur regions along with the sphere so they keep pointing at the same things
+	tx := h.DB.Begin()
+	var hotspots []models.Hotspot
+	tx.Where("scene_id = ?", scene.ID).Find(&hotspots)
+	for _, hs := range hotspots {
+		yaw, pitch := pipeline.Reorient(hs.Yaw, hs.Pitch, from, req)
+		if err := tx.Model(&hs).Updates(map[string]interface{}{"yaw": yaw, "pitch": pitch}).Error; err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update hotspots"})
+		}
 	}
 
+	// Blur regions are stored in viewer coordinates too, the slicer maps them through the new rotation
+	var blurRegions []models.BlurRegion
+	tx.Where("scene_id = ?", scene.ID).Find(&blurRegions)
+	for _, br := range blurRegions {
+		region := pipeline.BlurRegion{Shape: br.Shape}
+		if err := json.Unmarshal([]byte(br.Points), &region.Points); err != nil || !region.Valid() {
+			continue
+		}
+		region = region.Reorient(from, req)
+		points, _ := json.Marshal(region.Points)
+		if err := tx.Model(&br).Updates(map[string]interface{}{"shape": region.Shape, "points": string(points)}).Error; err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update blur regions"})
+		}
+	}
+
+	scene.CorrectionYaw = req.Yaw
+	scene.CorrectionPitch = req.Pitch
+	scene.CorrectionRoll = req.Roll
+	scene.Status = "processing"
+	if err := tx.Save(&scene).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update scene"})
+	}
+	tx.Commit()
+
+	opts := sceneOptions(scene, project)
+	opts.Rotation = req
+	opts.AutoLevel = false
+	go resliceScene(h.DB, h.R2, scene, opts)
+
+	return c.JSON(scene)
+}
+
+func (h *ProjectHandler) UploadMedia(c *fiber.Ctx) error {
+	// Get user to check quota/permissions
+	user := auth.CurrentUser(c)
+
 	form, err := c.MultipartForm()
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Failed to parse form"})
@@ -522,7 +733,7 @@ func (h *ProjectHandler) UploadMedia(c *fiber.Ctx) error {
 		totalSize += file.Size
 	}
 
-	if user.StorageUsed+totalSize > quotaMB*1024*1024 {
+	if !reserveStorage(h.DB, user.ID, totalSize, quotaMB*1024*1024) {
 		return c.Status(403).JSON(fiber.Map{"error": "Storage quota exceeded"})
 	}
 
@@ -538,6 +749,7 @@ func (h *ProjectHandler) UploadMedia(c *fiber.Ctx) error {
 		if err := c.SaveFile(file, filePath); err != nil {
 			continue
 		}
+		pipeline.StripMetadata(filePath, false)
 
 		// Upload to R2
 		if h.R2 != nil {
@@ -553,9 +765,18 @@ func (h *ProjectHandler) UploadMedia(c *fiber.Ctx) error {
 		}
 	}
 
-	// Update user storage
-	user.StorageUsed += totalSize
-	h.DB.Save(&user)
-
 	return c.JSON(fiber.Map{"urls": savedUrls})
 }
+
+// reserveStorage adds n bytes to a user's storage usage in one statement, unless
+// that would take them over quota. Concurrent uploads cannot both slip under it.
+func reserveStorage(db *gorm.DB, userID uint, n, quota int64) bool {
+	res := db.Model(&models.User{}).Where("id = ? AND storage_used + ? <= ?", userID, n, quota).
+		UpdateColumn("storage_used", gorm.Expr("storage_used + ?", n))
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+// releaseStorage gives back n bytes of a user's storage usage
+func releaseStorage(db *gorm.DB, userID uint, n int64) {
+	db.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("storage_used", gorm.Expr("GREATEST(storage_used - ?, 0)", n))
+}
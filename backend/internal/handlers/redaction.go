@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/pipeline"
+)
+
+// loadBlurRegions returns the redactions the pipeline should apply to a scene
+func loadBlurRegions(db *gorm.DB, sceneID string) []pipeline.BlurRegion {
+	var rows []models.BlurRegion
+	db.Where("scene_id = ?", sceneID).Find(&rows)
+
+	var regions []pipeline.BlurRegion
+	for _, row := range rows {
+		r := pipeline.BlurRegion{Shape: row.Shape}
+		if err := json.Unmarshal([]byte(row.Points), &r.Points); err == nil && r.Valid() {
+			regions = append(regions, r.Normalize())
+		}
+	}
+	return regions
+}
+
+func (h *ProjectHandler) GetBlurRegions(c *fiber.Ctx) error {
+	sceneID := c.Params("sceneID")
+
+	var regions []models.BlurRegion
+	h.DB.Where("scene_id = ?", sceneID).Order("id").Find(&regions)
+	return c.JSON(regions)
+}
+
+func (h *ProjectHandler) SaveBlurRegions(c *fiber.Ctx) error {
+	sceneID := c.Params("sceneID")
+	userID := c.Locals("user_id").(uint)
+	scene := *currentScene(c)
+	project := *currentProject(c)
+
+	if scene.Status == "processing" {
+		return c.Status(409).JSON(fiber.Map{"error": "Scene is still processing"})
+	}
+	// Only the poster would be blurred, the HLS renditions would still show everything
+	if scene.Kind == "video" {
+		return c.Status(400).JSON(fiber.Map{"error": "Blur regions are not supported for video scenes"})
+	}
+
+	type RegionRequest struct {
+		Shape  string              `json:"shape"`
+		Points []pipeline.YawPitch `json:"points"`
+		Label  string              `json:"label"`
+	}
+	var req []RegionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	for i, r := range req {
+		if !(pipeline.BlurRegion{Shape: r.Shape, Points: r.Points}).Valid() {
+			return c.Status(400).JSON(fiber.Map{"error": "Each region needs a shape of rect (2 points) or polygon (3+ points)"})
+		}
+		req[i].Points = pipeline.BlurRegion{Shape: r.Shape, Points: r.Points}.Normalize().Points
+	}
+
+	tx := h.DB.Begin()
+	if err := tx.Where("scene_id = ?", sceneID).Delete(&models.BlurRegion{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reset blur regions"})
+	}
+
+	var labels []string
+	regions := []models.BlurRegion{}
+	for _, r := range req {
+		points, _ := json.Marshal(r.Points)
+		region := models.BlurRegion{
+			SceneID:   sceneID,
+			Shape:     r.Shape,
+			Points:    string(points),
+			Label:     r.Label,
+			CreatedBy: userID,
+		}
+		if err := tx.Create(&region).Error; err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to save blur region"})
+		}
+		regions = append(regions, region)
+		if r.Label != "" {
+			labels = append(labels, r.Label)
+		}
+	}
+	tx.Commit()
+
+	details := fmt.Sprintf("%d region(s)", len(regions))
+	if len(labels) > 0 {
+		details += ": " + strings.Join(labels, ", ")
+	}
+	logAudit(h.DB, userID, "Redact Scene", fmt.Sprintf("Scene: %s (Project: %s)", sceneID, project.Name), details)
+
+	go resliceScene(h.DB, h.R2, scene, sceneOptions(scene, project))
+
+	return c.JSON(regions)
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/pipeline"
+	"a360-platform/backend/internal/s3"
+)
+
+// sliceScene runs the pipeline for one scene, publishes the output to R2 when
+// configured and updates the scene and project status. Meant to run in a goroutine.
+func sliceScene(db *gorm.DB, r2 *s3.R2Service, sid, pid, fpath string, opts pipeline.Options) {
+	slicingSemaphore <- struct{}{}
+	defer func() { <-slicingSemaphore }()
+	runSlice(db, r2, sid, pid, filepath.Dir(fpath), fpath, opts)
+}
+
+// runSlice does the work of sliceScene; the caller holds a slicing slot. The output
+// goes to sceneDir, input may live elsewhere and is moved to where the original
+// belongs once slicing succeeded: public in sceneDir, or private for redacted scenes.
+func runSlice(db *gorm.DB, r2 *s3.R2Service, sid, pid, sceneDir, input string, opts pipeline.Options) {
+	defer recoverSlicing(db, sid, pid)
+
+	ctx := context.Background()
+	opts.Branding = loadBranding(db, r2, pid)
+	opts.BlurRegions = loadBlurRegions(db, sid)
+	cubeDir := filepath.Join(sceneDir, "cubemap")
+	res, err := pipeline.SlicePanoWithOptions(input, cubeDir, opts)
+
+	updates := map[string]interface{}{"status": "ready", "processing_error": ""}
+	if err != nil {
+		updates["status"] = "error"
+		updates["processing_error"] = err.Error()
+	} else {
+		sceneResultUpdates(updates, pid, sid, pipeline.NewBundleScene(res, sceneDir, opts))
+	}
+
+	name := filepath.Base(input)
+	publicPath := filepath.Join(sceneDir, name)
+	privatePath := privateOriginalPath(pid, sid, name)
+	if err == nil && len(opts.BlurRegions) > 0 {
+		// Redacted scenes keep their original off the public paths
+		os.MkdirAll(filepath.Dir(privatePath), 0755)
+		if err := moveFile(input, privatePath); err == nil {
+			os.Remove(publicPath)
+			if r2 != nil {
+				r2.DeleteFile(ctx, fmt.Sprintf("%s/%s/%s", pid, sid, name))
+			}
+		}
+	} else if err == nil {
+		if input != publicPath {
+			moveFile(input, publicPath)
+		}
+		os.Remove(privatePath)
+	}
+
+	if err == nil && r2 != nil {
+		// Upload original, faces and thumbnail with the same layout as on disk
+		publishSceneDir(ctx, r2, fmt.Sprintf("%s/%s", pid, sid), sceneDir)
+
+		// Cleanup local scene dir
+		os.RemoveAll(sceneDir)
+	}
+
+	db.Model(&models.Scene{}).Where("id = ?", sid).Updates(updates)
+	updateProjectStatus(db, pid)
+}
+
+// recoverSlicing turns a panic in the pipeline, e.g. on a malformed input, into a
+// failed scene instead of taking the server down with the scene stuck processing
+func recoverSlicing(db *gorm.DB, sid, pid string) {
+	if r := recover(); r != nil {
+		log.Printf("[PIPELINE] Slicing scene %s panicked: %v", sid, r)
+		db.Model(&models.Scene{}).Where("id = ?", sid).Updates(map[string]interface{}{"status": "error", "processing_error": "Processing failed unexpectedly"})
+		updateProjectStatus(db, pid)
+	}
+}
+
+// updateProjectStatus marks the project ready once no scene is processing anymore
+func updateProjectStatus(db *gorm.DB, pid string) {
+	var unfinished int64
+	db.Model(&models.Scene{}).Where("project_id = ? AND status = ?", pid, "processing").Count(&unfinished)
+	if unfinished == 0 {
+		db.Model(&models.Project{}).Where("id = ?", pid).Update("status", "ready")
+	}
+}
+
+// processVideoScene extracts the poster frame and HLS renditions of a video scene,
+// keeps the source video private and slices the poster like any other scene
+func processVideoScene(db *gorm.DB, r2 *s3.R2Service, sid, pid, videoPath, fpath string, opts pipeline.Options) {
+	transcodeSemaphore <- struct{}{}
+	sceneDir := filepath.Dir(fpath)
+	res, err := pipeline.ProcessVideo(videoPath, fpath, filepath.Join(sceneDir, "hls"))
+	<-transcodeSemaphore
+
+	privateVideo := privateOriginalPath(pid, sid, filepath.Base(videoPath))
+	os.MkdirAll(filepath.Dir(privateVideo), 0755)
+	moveFile(videoPath, privateVideo)
+
+	if err != nil {
+		db.Model(&models.Scene{}).Where("id = ?", sid).Updates(map[string]interface{}{"status": "error", "processing_error": err.Error()})
+		updateProjectStatus(db, pid)
+		return
+	}
+
+	db.Model(&models.Scene{}).Where("id = ?", sid).Updates(map[string]interface{}{
+		"video_path":   fmt.Sprintf("uploads/%s/%s/hls/%s", pid, sid, filepath.Base(res.Playlist)),
+		"duration":     res.Info.Duration,
+		"video_width":  res.Info.Width,
+		"video_height": res.Info.Height,
+		"video_codec":  res.Info.Codec,
+	})
+	sliceScene(db, r2, sid, pid, fpath, opts)
+}
+
+// assembleScene rebuilds the equirect original of a cube layout upload at fpath, then slices it
+func assembleScene(db *gorm.DB, r2 *s3.R2Service, sid, pid string, sources []string, layout, fpath string, opts pipeline.Options) {
+	slicingSemaphore <- struct{}{}
+	err := pipeline.AssembleEquirect(sources, layout, fpath)
+	<-slicingSemaphore
+	os.RemoveAll(filepath.Dir(sources[0]))
+
+	if err != nil {
+		db.Model(&models.Scene{}).Where("id = ?", sid).Updates(map[string]interface{}{"status": "error", "processing_error": err.Error()})
+		updateProjectStatus(db, pid)
+		return
+	}
+	sliceScene(db, r2, sid, pid, fpath, opts)
+}
+
+// sceneResultUpdates adds the columns a sliced scene stores to updates
+func sceneResultUpdates(updates map[string]interface{}, pid, sid string, bs pipeline.BundleScene) {
+	if b, err := json.Marshal(bs.Options); err == nil {
+		updates["pipeline_options"] = string(b)
+	}
+	rot := bs.Options.Rotation
+	updates["correction_yaw"] = rot.Yaw
+	updates["correction_pitch"] = rot.Pitch
+	updates["correction_roll"] = rot.Roll
+	updates["stereo_mode"] = bs.Manifest.Stereo
+	updates["projection"] = bs.Options.Projection
+	updates["tone_map"] = bs.Options.ToneMap
+	updates["exposure"] = bs.Options.Exposure
+	updates["manifest"] = bs.Manifest.JSON()
+	updates["blurhash"] = bs.Manifest.Blurhash
+	updates["preview_path"] = fmt.Sprintf("uploads/%s/%s/%s", pid, sid, bs.Manifest.Preview)
+	updates["h_fov"] = bs.Coverage.HFov
+	updates["v_fov"] = bs.Coverage.VFov
+	updates["min_pitch"] = bs.Coverage.MinPitch()
+	updates["max_pitch"] = bs.Coverage.MaxPitch()
+	updates["initial_yaw"] = bs.InitialYaw
+	updates["initial_pitch"] = bs.InitialPitch
+	// A re-slice reads the stripped original, so only fields actually found are
+	// written; what was captured from the first upload is kept otherwise
+	if md := bs.Metadata; md != nil {
+		if md.CapturedAt != nil {
+			updates["captured_at"] = md.CapturedAt
+		}
+		if md.CameraMake != "" || md.CameraModel != "" {
+			updates["camera_make"] = md.CameraMake
+			updates["camera_model"] = md.CameraModel
+		}
+		if md.Latitude != nil && md.Longitude != nil {
+			updates["latitude"] = md.Latitude
+			updates["longitude"] = md.Longitude
+			updates["altitude"] = md.Altitude
+		}
+		if gp := md.GPano; gp != nil {
+			updates["pose_heading"] = gp.PoseHeadingDegrees
+			updates["pose_pitch"] = gp.PosePitchDegrees
+			updates["pose_roll"] = gp.PoseRollDegrees
+		}
+	}
+}
+
+// sceneOptions returns the pipeline options a scene was last sliced with
+func sceneOptions(scene models.Scene, project models.Project) pipeline.Options {
+	opts := pipeline.Options{Stereo: scene.StereoMode, StripMetadata: true}
+	if scene.PipelineOptions != "" {
+		json.Unmarshal([]byte(scene.PipelineOptions), &opts)
+	}
+	opts.KeepLocation = project.KeepGeolocation
+	opts.InitialView = &pipeline.YawPitch{Yaw: scene.InitialYaw, Pitch: scene.InitialPitch}
+	opts.ThumbnailView = nil
+	if scene.ThumbnailYaw != nil && scene.ThumbnailPitch != nil {
+		opts.ThumbnailView = &pipeline.YawPitch{Yaw: *scene.ThumbnailYaw, Pitch: *scene.ThumbnailPitch}
+	}
+	return opts
+}
+
+// resliceScene fetches the original of an already processed scene and runs the pipeline again.
+// The scene shows as processing straight away, the original is only fetched once a slot is free.
+func resliceScene(db *gorm.DB, r2 *s3.R2Service, scene models.Scene, opts pipeline.Options) {
+	db.Model(&models.Scene{}).Where("id = ?", scene.ID).Update("status", "processing")
+	db.Model(&models.Project{}).Where("id = ?", scene.ProjectID).Update("status", "processing")
+
+	slicingSemaphore <- struct{}{}
+	defer func() { <-slicingSemaphore }()
+	defer recoverSlicing(db, scene.ID, scene.ProjectID)
+
+	// Fetched originals are staged outside ./uploads: a redacted scene's original must
+	// never be served, not even while slicing or after a failed run
+	tmpDir, err := os.MkdirTemp("", "reslice-*")
+	if err != nil {
+		db.Model(&models.Scene{}).Where("id = ?", scene.ID).Updates(map[string]interface{}{"status": "error", "processing_error": err.Error()})
+		updateProjectStatus(db, scene.ProjectID)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sceneDir := "./" + scene.PanoPath
+	os.MkdirAll(sceneDir, 0755)
+	original := sceneOriginalFile(scene)
+	fpath := filepath.Join(sceneDir, original)
+	if privatePath := privateOriginalPath(scene.ProjectID, scene.ID, original); fileExists(privatePath) {
+		fpath = filepath.Join(tmpDir, original)
+		if err := copyFile(privatePath, fpath); err != nil {
+			db.Model(&models.Scene{}).Where("id = ?", scene.ID).Updates(map[string]interface{}{"status": "error", "processing_error": err.Error()})
+			updateProjectStatus(db, scene.ProjectID)
+			return
+		}
+	} else if r2 != nil {
+		fpath = filepath.Join(tmpDir, original)
+		key := fmt.Sprintf("%s/%s/%s", scene.ProjectID, scene.ID, original)
+		if err := r2.DownloadFile(context.Background(), key, fpath); err != nil {
+			db.Model(&models.Scene{}).Where("id = ?", scene.ID).Updates(map[string]interface{}{"status": "error", "processing_error": err.Error()})
+			updateProjectStatus(db, scene.ProjectID)
+			return
+		}
+	}
+
+	runSlice(db, r2, scene.ID, scene.ProjectID, sceneDir, fpath, opts)
+}
+
+// encodingProfiles reads an output format list such as FACE_FORMATS="jpeg:85:progressive,webp:80"
+func encodingProfiles(envVar string) []pipeline.Profile {
+	profiles, err := pipeline.ParseProfiles(os.Getenv(envVar))
+	if err != nil {
+		log.Printf("[PIPELINE] Ignoring %s: %v", envVar, err)
+		return nil
+	}
+	return profiles
+}
+
+// extraContentTypes are missing from most system mime tables
+var extraContentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".hdr":  "image/vnd.radiance",
+	".exr":  "image/x-exr",
+}
+
+// publishSceneDir uploads every file below dir to R2 under prefix, keeping relative paths
+func publishSceneDir(ctx context.Context, r2 *s3.R2Service, prefix, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		contentType := extraContentTypes[filepath.Ext(path)]
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(path))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		return r2.UploadFile(ctx, prefix+"/"+filepath.ToSlash(rel), path, contentType)
+	})
+}
+
+// stripProjectOriginals runs the privacy pass again over every published original of a project
+func stripProjectOriginals(db *gorm.DB, r2 *s3.R2Service, pid string) {
+	var scenes []models.Scene
+	db.Where("project_id = ?", pid).Find(&scenes)
+
+	ctx := context.Background()
+	for _, scene := range scenes {
+		// HDR originals carry no EXIF or XMP to strip
+		original := sceneOriginalFile(scene)
+		if pipeline.IsHDRFile(original) {
+			continue
+		}
+		localPath := filepath.Join(scene.PanoPath, original)
+		if privatePath := privateOriginalPath(pid, scene.ID, original); fileExists(privatePath) {
+			localPath = privatePath
+		} else if r2 != nil {
+			localPath = ""
+		}
+		if localPath != "" {
+			pipeline.StripMetadata(localPath, false)
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/original.jpg", pid, scene.ID)
+		tmp, err := os.CreateTemp("", "original-*.jpg")
+		if err != nil {
+			continue
+		}
+		tmp.Close()
+		if err := r2.DownloadFile(ctx, key, tmp.Name()); err == nil {
+			if err := pipeline.StripMetadata(tmp.Name(), false); err == nil {
+				r2.UploadFile(ctx, key, tmp.Name(), "image/jpeg")
+			}
+		}
+		os.Remove(tmp.Name())
+	}
+}
+
+// privateOriginalPath is where originals of redacted scenes live. It is outside
+// ./uploads so it is never served, and never uploaded to the public bucket.
+func privateOriginalPath(pid, sid, name string) string {
+	return filepath.Join("private", pid, sid, name)
+}
+
+// sceneOriginalFile is the file name of the uploaded original inside the scene directory
+func sceneOriginalFile(scene models.Scene) string {
+	if scene.OriginalFile != "" {
+		return scene.OriginalFile
+	}
+	return "original.jpg"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func moveFile(src, dst string) error {
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+)
+
+// scoped confines an admin query to the org-admin's organization through the
+// given organization_id column; platform admins see everything
+func scoped(c *fiber.Ctx, db *gorm.DB, column string) *gorm.DB {
+	if org := auth.OrgScope(c); org != nil {
+		return db.Where(column+" = ?", *org)
+	}
+	return db
+}
+
+// membersOnly confines a query on a user-owned table to members of the
+// org-admin's organization through the given user_id column
+func membersOnly(c *fiber.Ctx, db *gorm.DB, column string) *gorm.DB {
+	if org := auth.OrgScope(c); org != nil {
+		return db.Where(column+" IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id").Where("organization_id = ?", *org))
+	}
+	return db
+}
+
+// targetOrganization returns the organization new records are created in: the
+// org-admin's own, or the one a platform admin asked for
+func (h *AdminHandler) targetOrganization(c *fiber.Ctx, requested *uint) (*uint, bool) {
+	if org := auth.OrgScope(c); org != nil {
+		return org, true
+	}
+	return requested, h.organizationExists(requested)
+}
+
+// manageable reports whether the caller may change a user: only members ranked
+// below the caller, except for super-admins who manage everyone
+func manageable(c *fiber.Ctx, user *models.User) bool {
+	role := auth.UserRole(auth.CurrentUser(c))
+	return role == auth.RoleSuperAdmin || rolePrivilege(auth.UserRole(user)) < rolePrivilege(role)
+}
+
+// manageableUsers keeps the users the caller may change, for bulk operations
+func manageableUsers(c *fiber.Ctx, users []models.User) []models.User {
+	var kept []models.User
+	for i := range users {
+		if manageable(c, &users[i]) {
+			kept = append(kept, users[i])
+		}
+	}
+	return kept
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+)
+
+// ListAPITokens returns the caller's personal access tokens, revoked ones included
+func (h *AuthHandler) ListAPITokens(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	var tokens []models.APIToken
+	h.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&tokens)
+	return c.JSON(tokens)
+}
+
+func (h *AuthHandler) CreateAPIToken(c *fiber.Ctx) error {
+	type Request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // defaults to 90
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Token name is required"})
+	}
+	if len(req.Scopes) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "At least one scope is required"})
+	}
+	user := auth.CurrentUser(c)
+	for _, scope := range req.Scopes {
+		if !auth.IsScope(scope) {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Unknown scope %q, use projects:read, projects:upload or admin", scope)})
+		}
+		if scope == auth.ScopeAdmin && !auth.Can(user, auth.PermManageUsers) && !auth.Can(user, auth.PermManageOrgMembers) {
+			return c.Status(403).JSON(fiber.Map{"error": "Only admins can create tokens with the admin scope"})
+		}
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = 90
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > auth.MaxAPITokenDays {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Expiry must be between 1 and %d days", auth.MaxAPITokenDays)})
+	}
+	expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+
+	token, raw, err := auth.CreateAPIToken(h.DB, user.ID, req.Name, req.Scopes, &expiresAt)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create token"})
+	}
+
+	logAudit(h.DB, user.ID, "Create API Token", user.Email, fmt.Sprintf("%s (%s), expires %s", token.Name, token.Scopes, expiresAt.Format("2006-01-02")))
+
+	// The plaintext token is only ever returned here
+	return c.JSON(fiber.Map{"token": raw, "api_token": token})
+}
+
+func (h *AuthHandler) RevokeAPIToken(c *fiber.Ctx) error {
+	user := auth.CurrentUser(c)
+
+	var token models.APIToken
+	if err := h.DB.Where("id = ? AND user_id = ?", c.Params("id"), user.ID).First(&token).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Token not found"})
+	}
+	if token.RevokedAt == nil {
+		now := time.Now()
+		token.RevokedAt = &now
+		h.DB.Model(&token).Update("revoked_at", now)
+		logAudit(h.DB, user.ID, "Revoke API Token", user.Email, token.Name)
+	}
+	return c.JSON(fiber.Map{"message": "Token revoked"})
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"a360-platform/backend/internal/auth"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/policy"
+	"a360-platform/backend/internal/totp"
+)
+
+// LoginTwoFactor is the second sign-in step: the challenge from Login plus an
+// authenticator code or a recovery code
+func (h *AuthHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	type Request struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	userID, err := auth.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	}
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil || !user.TOTPEnabled {
+		return c.Status(401).JSON(fiber.Map{"error": auth.ErrInvalidChallenge.Error()})
+	}
+	if !user.IsActive {
+		return c.Status(403).JSON(fiber.Map{"error": "Account deactivated. Please contact A360 Workshop Team."})
+	}
+	user.Phase = policy.Phase(&user, time.Now())
+	if user.Phase == policy.PhaseLocked {
+		return c.Status(403).JSON(fiber.Map{"error": "Account membership expired. Please contact A360 Workshop Team for extensions."})
+	}
+
+	switch {
+	case req.Code != "":
+		if !auth.VerifyTOTP(h.DB, &user, req.Code) {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid authentication code"})
+		}
+	case req.RecoveryCode != "":
+		if !auth.UseRecoveryCode(h.DB, user.ID, req.RecoveryCode) {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid recovery code"})
+		}
+		logAudit(h.DB, user.ID, "Use Recovery Code", user.Email, fmt.Sprintf("%d recovery codes left", h.recoveryCodesLeft(user.ID)))
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "Authentication code is required"})
+	}
+
+	return h.completeLogin(c, &user)
+}
+
+// GetTwoFactor reports the caller's two-factor status
+func (h *AuthHandler) GetTwoFactor(c *fiber.Ctx) error {
+	user := auth.CurrentUser(c)
+	return c.JSON(fiber.Map{
+		"enabled":                  user.TOTPEnabled,
+		"required":                 auth.TwoFactorRequired(user),
+		"recovery_codes_remaining": h.recoveryCodesLeft(user.ID),
+	})
+}
+
+// SetupTwoFactor starts enrolment with a new secret; it takes effect once a code
+// from the authenticator app is confirmed through EnableTwoFactor
+func (h *AuthHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	user := auth.CurrentUser(c)
+	if user.TOTPEnabled {
+		return c.Status(409).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate secret"})
+	}
+	if err := h.DB.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start enrolment"})
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": totp.ProvisioningURI(auth.TOTPIssuer, user.Email, secret),
+	})
+}
+
+func (h *AuthHandler) EnableTwoFactor(c *fiber.Ctx) error {
+	type Request struct {
+		Code string `json:"code"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	user := auth.CurrentUser(c)
+	if user.TOTPEnabled {
+		return c.Status(409).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+	if user.TOTPSecret == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Start the setup first"})
+	}
+	if !auth.VerifyTOTP(h.DB, user, req.Code) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid authentication code"})
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(h.DB, user.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate recovery codes"})
+	}
+	h.DB.Model(user).Update("totp_enabled", true)
+	// Sessions and tokens minted before enrolment would otherwise keep working
+	// without the second factor; only the session that just enrolled survives
+	current, _ := c.Locals("session_id").(string)
+	auth.RevokeOtherSessions(h.DB, user.ID, current, auth.RevokedTwoFactorOn)
+	auth.RevokeUserAPITokens(h.DB, user.ID)
+
+	logAudit(h.DB, user.ID, "Enable 2FA", user.Email, "Other sessions and API tokens revoked")
+
+	// Recovery codes are only ever shown here and when regenerated
+	return c.JSON(fiber.Map{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	type Request struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	user := auth.CurrentUser(c)
+	if !user.TOTPEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+	if auth.TwoFactorRequired(user) {
+		return c.Status(403).JSON(fiber.Map{"error": "Two-factor authentication is required for admin accounts"})
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid password"})
+	}
+	if !auth.VerifyTOTP(h.DB, user, req.Code) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid authentication code"})
+	}
+
+	if err := auth.ResetTwoFactor(h.DB, user); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to disable two-factor authentication"})
+	}
+	logAudit(h.DB, user.ID, "Disable 2FA", user.Email, "")
+
+	return c.JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, invalidating the old ones
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	type Request struct {
+		Code string `json:"code"`
+	}
+	var req Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	user := auth.CurrentUser(c)
+	if !user.TOTPEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+	if !auth.VerifyTOTP(h.DB, user, req.Code) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid authentication code"})
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(h.DB, user.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate recovery codes"})
+	}
+	logAudit(h.DB, user.ID, "Regenerate Recovery Codes", user.Email, "")
+
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+func (h *AuthHandler) recoveryCodesLeft(userID uint) int64 {
+	var count int64
+	h.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// ResetUserTwoFactor removes a member's authenticator, e.g. after a lost phone.
+// They sign in with their password again and, if required, re-enrol.
+func (h *AdminHandler) ResetUserTwoFactor(c *fiber.Ctx) error {
+	var user models.User
+	if err := scoped(c, h.DB, "organization_id").First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+	if !manageable(c, &user) {
+		return c.Status(403).JSON(fiber.Map{"error": "You cannot manage this account"})
+	}
+	// Weakening another admin's sign-in is a role decision
+	if auth.Can(&user, auth.PermManageUsers) && !auth.Can(auth.CurrentUser(c), auth.PermManageRoles) {
+		return c.Status(403).JSON(fiber.Map{"error": "Only super-admins can reset an admin's two-factor authentication"})
+	}
+	if !user.TOTPEnabled && user.TOTPSecret == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled for this user"})
+	}
+
+	if err := auth.ResetTwoFactor(h.DB, &user); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reset two-factor authentication"})
+	}
+	auth.RevokeUserSessions(h.DB, user.ID, auth.RevokedTwoFactorReset)
+	auth.RevokeUserAPITokens(h.DB, user.ID)
+
+	adminID := c.Locals("user_id").(uint)
+	h.logAdminAction(adminID, "Reset 2FA", user.Email, "Authenticator and recovery codes removed, sessions and API tokens revoked")
+
+	return c.JSON(fiber.Map{"message": "Two-factor authentication reset"})
+}
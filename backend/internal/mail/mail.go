@@ -2,21 +2,30 @@ package mail
 
 import (
 	"fmt"
+	"html"
 	"log"
 	"net/smtp"
 	"os"
+	"time"
 )
 
-func SendInvitation(toEmail, token string) error {
+// Sender is the identity emails are shown to come from; SMTP_EMAIL still sends them
+type Sender struct {
+	Name  string
+	Email string
+}
+
+// DefaultSender is the platform identity, used when an organization sets none
+var DefaultSender = Sender{Name: "A360 Workshop Platform", Email: "noreply@a360.co.th"}
+
+func SendInvitation(sender Sender, toEmail, token string) error {
 	log.Printf("[MAIL] Sending Invitation to: %s", toEmail)
 	from := os.Getenv("SMTP_EMAIL")
 	password := os.Getenv("SMTP_PASSWORD")
 	smtpHost := "smtp.gmail.com"
 	smtpPort := "587"
 
-	// Sender metadata
-	senderName := "A360 Workshop Platform"
-	displayEmail := "noreply@a360.co.th"
+	senderName, displayEmail := sender.Name, sender.Email
 	subject := "Invitation to join A360 Workshop Platform"
 
 	// Base URL for registration (pointing to frontend)
@@ -55,15 +64,14 @@ func SendInvitation(toEmail, token string) error {
 	}
 	return nil
 }
-func SendWelcome(toEmail string) error {
+func SendWelcome(sender Sender, toEmail string, creativeDays, viewOnlyDays int) error {
 	log.Printf("[MAIL] Sending Welcome to: %s", toEmail)
 	from := os.Getenv("SMTP_EMAIL")
 	password := os.Getenv("SMTP_PASSWORD")
 	smtpHost := "smtp.gmail.com"
 	smtpPort := "587"
 
-	senderName := "A360 Workshop Platform"
-	displayEmail := "noreply@a360.co.th"
+	senderName, displayEmail := sender.Name, sender.Email
 	subject := "Welcome to A360! | Account Created Successfully"
 
 	body := fmt.Sprintf("MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n"+
@@ -77,26 +85,144 @@ func SendWelcome(toEmail string) error {
 		"<p>Your institutional account has been successfully created. We are excited to have you join our immersive archive!</p>"+
 		"<p><strong>Institutional Access Terms:</strong></p>"+
 		"<ul>"+
-		"<li><strong>Workshop Duration (30 Days):</strong> You have full access to create, edit, and upload new virtual tours.</li>"+
-		"<li><strong>View-Only Phase (Next 60 Days):</strong> After your workshop expires, you can still view and share your existing tours for an additional 60 days. Access to creation tools will be disabled.</li>"+
-		"<li><strong>Final Lockout (After 90 Days):</strong> Your account will be locked after 90 days of total membership.</li>"+
+		"<li><strong>Workshop Duration (%d Days):</strong> You have full access to create, edit, and upload new virtual tours.</li>"+
+		"<li><strong>View-Only Phase (Next %d Days):</strong> After your workshop expires, you can still view and share your existing tours for an additional %d days. Access to creation tools will be disabled.</li>"+
+		"<li><strong>Final Lockout (After %d Days):</strong> Your account will be locked after %d days of total membership.</li>"+
 		"</ul>"+
 		"<p>If you wish to extend your license or have any questions, please contact the <strong>A360 Workshop Team</strong>.</p>"+
 		"<p>Best regards,<br><strong>%s</strong></p>"+
-		"</body></html>", senderName, displayEmail, displayEmail, toEmail, subject, senderName)
+		"</body></html>", senderName, displayEmail, displayEmail, toEmail, subject,
+		creativeDays, viewOnlyDays, viewOnlyDays, creativeDays+viewOnlyDays, creativeDays+viewOnlyDays, senderName)
+
+	auth := smtp.PlainAuth("", from, password, smtpHost)
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{toEmail}, []byte(body))
+}
+
+// SendPhaseReminder warns a member that their creative (phase "creative") or
+// view-only (phase "view_only") access ends in daysLeft days
+func SendPhaseReminder(sender Sender, toEmail, phase string, daysLeft int, endsAt time.Time) error {
+	log.Printf("[MAIL] Sending %s reminder to: %s", phase, toEmail)
+	from := os.Getenv("SMTP_EMAIL")
+	password := os.Getenv("SMTP_PASSWORD")
+	smtpHost := "smtp.gmail.com"
+	smtpPort := "587"
+
+	senderName, displayEmail := sender.Name, sender.Email
+
+	when := "in 1 day"
+	if daysLeft > 1 {
+		when = fmt.Sprintf("in %d days", daysLeft)
+	}
+	date := endsAt.Format("2 January 2006")
+
+	subject := "Your A360 Workshop access is ending " + when
+	heading := "Your Creative Phase is ending soon"
+	details := "<p>Your workshop access ends <strong>" + when + "</strong> (" + date + "). After that date you can still view and share your existing tours, but creation and editing tools will be disabled.</p>" +
+		"<p>Please finish any uploads and edits you have planned before then.</p>"
+	if phase == "view_only" {
+		subject = "Your A360 account will be locked " + when
+		heading = "Your View-Only Phase is ending soon"
+		details = "<p>Your account will be locked <strong>" + when + "</strong> (" + date + "). Your tours will no longer be viewable or shared after that date.</p>"
+	}
+
+	body := fmt.Sprintf("MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n"+
+		"From: %s <%s>\r\n"+
+		"Reply-To: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"\r\n"+
+		"<html><body style=\"font-family: Arial, sans-serif; line-height: 1.6; color: #333;\">"+
+		"<h2>%s</h2>"+
+		"%s"+
+		"<p>If you wish to extend your license, please contact the <strong>A360 Workshop Team</strong>.</p>"+
+		"<p>Best regards,<br><strong>%s</strong></p>"+
+		"</body></html>", senderName, displayEmail, displayEmail, toEmail, subject, heading, details, senderName)
+
+	auth := smtp.PlainAuth("", from, password, smtpHost)
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{toEmail}, []byte(body))
+}
+
+// SendExtensionRequested notifies the admins of a new license extension request
+func SendExtensionRequested(sender Sender, adminEmails []string, userEmail, reason string, days int) error {
+	if len(adminEmails) == 0 {
+		return nil
+	}
+	log.Printf("[MAIL] Sending extension request from %s to %d admins", userEmail, len(adminEmails))
+	from := os.Getenv("SMTP_EMAIL")
+	password := os.Getenv("SMTP_PASSWORD")
+	smtpHost := "smtp.gmail.com"
+	smtpPort := "587"
+
+	senderName, displayEmail := sender.Name, sender.Email
+	subject := "License extension request from " + userEmail
+
+	baseURL := os.Getenv("FRONTEND_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:5173"
+	}
+
+	body := fmt.Sprintf("MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n"+
+		"From: %s <%s>\r\n"+
+		"Reply-To: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"\r\n"+
+		"<html><body style=\"font-family: Arial, sans-serif; line-height: 1.6; color: #333;\">"+
+		"<h2>New License Extension Request</h2>"+
+		"<p><strong>%s</strong> has asked for <strong>%d more days</strong> of creative access.</p>"+
+		"<p><strong>Reason:</strong><br>%s</p>"+
+		"<p><a href=\"%s/admin\">Review the request in the Admin Console</a></p>"+
+		"<p>Best regards,<br><strong>%s</strong></p>"+
+		"</body></html>", senderName, displayEmail, displayEmail, displayEmail, subject,
+		html.EscapeString(userEmail), days, html.EscapeString(reason), baseURL, senderName)
+
+	auth := smtp.PlainAuth("", from, password, smtpHost)
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, adminEmails, []byte(body))
+}
+
+// SendExtensionDecision tells a member whether their extension request was approved
+func SendExtensionDecision(sender Sender, toEmail string, approved bool, days int, expiresAt time.Time, note string) error {
+	log.Printf("[MAIL] Sending extension decision to: %s", toEmail)
+	from := os.Getenv("SMTP_EMAIL")
+	password := os.Getenv("SMTP_PASSWORD")
+	smtpHost := "smtp.gmail.com"
+	smtpPort := "587"
+
+	senderName, displayEmail := sender.Name, sender.Email
+
+	subject := "Your A360 license extension was approved"
+	details := fmt.Sprintf("<p>Your creative access has been extended by <strong>%d days</strong> and now ends on <strong>%s</strong>.</p>", days, expiresAt.Format("2 January 2006"))
+	if !approved {
+		subject = "Your A360 license extension request"
+		details = "<p>Unfortunately your license extension request could not be approved.</p>"
+	}
+	if note != "" {
+		details += "<p><strong>Note from the A360 Workshop Team:</strong><br>" + html.EscapeString(note) + "</p>"
+	}
+
+	body := fmt.Sprintf("MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n"+
+		"From: %s <%s>\r\n"+
+		"Reply-To: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"\r\n"+
+		"<html><body style=\"font-family: Arial, sans-serif; line-height: 1.6; color: #333;\">"+
+		"<h2>License Extension Request</h2>"+
+		"%s"+
+		"<p>Best regards,<br><strong>%s</strong></p>"+
+		"</body></html>", senderName, displayEmail, displayEmail, toEmail, subject, details, senderName)
 
 	auth := smtp.PlainAuth("", from, password, smtpHost)
 	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{toEmail}, []byte(body))
 }
 
-func SendResetPassword(toEmail, token string) error {
+func SendResetPassword(sender Sender, toEmail, token string) error {
 	from := os.Getenv("SMTP_EMAIL")
 	password := os.Getenv("SMTP_PASSWORD")
 	smtpHost := "smtp.gmail.com"
 	smtpPort := "587"
 
-	senderName := "A360 Workshop Platform"
-	displayEmail := "noreply@a360.co.th"
+	senderName, displayEmail := sender.Name, sender.Email
 	subject := "Reset Your A360 Password"
 
 	baseURL := os.Getenv("FRONTEND_URL")
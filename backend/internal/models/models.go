@@ -7,59 +7,133 @@ import (
 )
 
 type User struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Email        string         `gorm:"unique;not null" json:"email"`
-	FullName     string         `json:"full_name"`
-	UserType     string         `json:"user_type"` // Student, Teacher/Professor, Anonymous
-	Password     string         `gorm:"not null" json:"-"`
-	StorageUsed  int64          `json:"storage_used"`  // in bytes
-	StorageQuota int64          `json:"storage_quota"` // in bytes
-	ProjectLimit int            `json:"project_limit"` // max number of projects
-	IsActive     bool           `gorm:"default:true" json:"is_active"`
-	IsAdmin      bool           `gorm:"default:false" json:"is_admin"`
-	Projects     []Project      `json:"projects,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-	RegSource    string         `json:"reg_source"` // e.g. "Invitation", "Code:ABCDEF"
-	ValidFrom    time.Time      `json:"valid_from"`
-	ExpiresAt    time.Time      `json:"expires_at"`
-	ResetToken   string         `json:"reset_token"`
-	ResetExpires *time.Time     `json:"reset_expires"`
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	Email          string            `gorm:"unique;not null" json:"email"`
+	FullName       string            `json:"full_name"`
+	UserType       string            `json:"user_type"` // Student, Teacher/Professor, Anonymous
+	Password       string            `gorm:"not null" json:"-"`
+	StorageUsed    int64             `json:"storage_used"`  // in bytes
+	StorageQuota   int64             `json:"storage_quota"` // in bytes
+	ProjectLimit   int               `json:"project_limit"` // max number of projects
+	IsActive       bool              `gorm:"default:true" json:"is_active"`
+	IsAdmin        bool              `gorm:"default:false" json:"is_admin"`
+	Role           string            `gorm:"default:'student'" json:"role"` // student, instructor, org_admin, admin, super_admin
+	Projects       []Project         `json:"projects,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt    `gorm:"index" json:"-"`
+	RegSource      string            `json:"reg_source"` // e.g. "Invitation", "Code:ABCDEF"
+	ValidFrom      time.Time         `json:"valid_from"`
+	ExpiresAt      time.Time         `json:"expires_at"`
+	ResetToken     string            `json:"-"` // sha256 of the emailed reset token
+	ResetExpires   *time.Time        `json:"-"`
+	LocksAt        time.Time         `json:"locks_at"`  // end of the view-only phase, ExpiresAt ends the creative phase
+	PolicyID       *uint             `json:"policy_id"` // membership policy the dates were derived from
+	CohortID       *uint             `gorm:"index" json:"cohort_id"`
+	CohortDisabled bool              `gorm:"default:false" json:"-"`       // deactivated along with the cohort, reactivated with it
+	OrganizationID *uint             `gorm:"index" json:"organization_id"` // tenant the user belongs to, nil for platform staff
+	Organization   *Organization     `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Policy         *MembershipPolicy `gorm:"foreignKey:PolicyID" json:"policy,omitempty"`
+	Phase          string            `gorm:"-" json:"phase,omitempty"` // creative, view_only, locked; evaluated per request
+	TOTPSecret     string            `json:"-"`                        // base32 authenticator secret, set once enrolment starts
+	TOTPEnabled    bool              `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep   int64             `json:"-"` // last accepted time step, so a code cannot be used twice
 }
 
 type Project struct {
-	ID        string         `gorm:"primaryKey;type:uuid" json:"id"`
-	UserID    uint           `json:"user_id"`
-	Name      string         `json:"name"`
-	PanoPath  string         `json:"pano_path"`
-	IsPublic  bool           `gorm:"default:false" json:"is_public"`
-	IsActive  bool           `gorm:"default:true" json:"is_active"`
-	MagicCode string         `gorm:"uniqueIndex" json:"magic_code"` // Short unique code for public tours
-	Manifest  string         `gorm:"type:text" json:"manifest"`     // JSON string for hotspots
-	Size      int64          `json:"size"`                          // storage size in bytes
-	Hotspots  []Hotspot      `json:"hotspots"`
-	User      *User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Status    string         `gorm:"default:'ready'" json:"status"` // ready, processing, error
-	Views     int64          `gorm:"default:0" json:"views"`
-	Scenes    []Scene        `json:"scenes"`
+	ID              string         `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID          uint           `json:"user_id"`
+	Name            string         `json:"name"`
+	PanoPath        string         `json:"pano_path"`
+	IsPublic        bool           `gorm:"default:false" json:"is_public"`
+	IsActive        bool           `gorm:"default:true" json:"is_active"`
+	MagicCode       string         `gorm:"uniqueIndex" json:"magic_code"` // Short unique code for public tours
+	Manifest        string         `gorm:"type:text" json:"manifest"`     // JSON string for hotspots
+	Size            int64          `json:"size"`                          // storage size in bytes
+	Hotspots        []Hotspot      `json:"hotspots"`
+	User            *User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	Status          string         `gorm:"default:'ready'" json:"status"` // ready, processing, error
+	Views           int64          `gorm:"default:0" json:"views"`
+	Scenes          []Scene        `json:"scenes"`
+	KeepGeolocation bool           `gorm:"default:false" json:"keep_geolocation"` // keep GPS in published originals and the public tour API
+	UnlockedUntil   *time.Time     `json:"unlocked_until"`                        // owner may edit past their creative phase until then
+	UnlockedBy      *uint          `json:"unlocked_by"`
+	CoverSceneID    string         `json:"cover_scene_id"` // scene whose thumbnails represent the project, mirrored in PanoPath
 }
 
 type Scene struct {
-	ID           string         `gorm:"primaryKey;type:uuid" json:"id"`
-	ProjectID    string         `gorm:"index" json:"project_id"`
-	Name         string         `json:"name"`
-	PanoPath     string         `json:"pano_path"`
-	Status       string         `gorm:"default:'ready'" json:"status"` // ready, processing, error
-	DisplayOrder int            `json:"display_order"`
-	Size         int64          `json:"size"`
-	Hotspots     []Hotspot      `json:"hotspots"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              string         `gorm:"primaryKey;type:uuid" json:"id"`
+	ProjectID       string         `gorm:"index" json:"project_id"`
+	Name            string         `json:"name"`
+	PanoPath        string         `json:"pano_path"`
+	Status          string         `gorm:"default:'ready'" json:"status"` // ready, processing, error
+	DisplayOrder    int            `json:"display_order"`
+	Size            int64          `json:"size"`
+	Kind            string         `gorm:"default:'image'" json:"kind"`       // image, video
+	OriginalFile    string         `json:"original_file"`                     // uploaded original in the scene directory, original.jpg unless HDR
+	StereoMode      string         `gorm:"default:'mono'" json:"stereo_mode"` // mono, top-bottom, side-by-side
+	Manifest        string         `gorm:"type:text" json:"manifest"`         // JSON pipeline.Manifest of derived files
+	PreviewPath     string         `json:"preview_path"`                      // small equirect placeholder, e.g. uploads/<project>/<scene>/preview.jpg
+	Blurhash        string         `json:"blurhash"`
+	HFov            float64        `gorm:"default:360" json:"h_fov"` // degrees covered by the source image
+	VFov            float64        `gorm:"default:180" json:"v_fov"`
+	MinPitch        float64        `gorm:"default:-90" json:"min_pitch"` // valid pitch range for the viewer to clamp to
+	MaxPitch        float64        `gorm:"default:90" json:"max_pitch"`
+	InitialYaw      float64        `json:"initial_yaw"` // initial view relative to the image centre
+	InitialPitch    float64        `json:"initial_pitch"`
+	ThumbnailYaw    *float64       `json:"thumbnail_yaw"` // chosen thumbnail view, nil follows the initial view
+	ThumbnailPitch  *float64       `json:"thumbnail_pitch"`
+	CapturedAt      *time.Time     `json:"captured_at"` // capture metadata from EXIF and XMP GPano
+	CameraMake      string         `json:"camera_make"`
+	CameraModel     string         `json:"camera_model"`
+	Latitude        *float64       `json:"latitude"`
+	Longitude       *float64       `json:"longitude"`
+	Altitude        *float64       `json:"altitude"`
+	Projection      string         `json:"projection"` // how the upload was read: equirectangular, cylindrical or dual_fisheye
+	PoseHeading     *float64       `json:"pose_heading"`
+	PosePitch       *float64       `json:"pose_pitch"`
+	PoseRoll        *float64       `json:"pose_roll"`
+	ToneMap         string         `json:"tone_map,omitempty"` // operator for HDR originals: reinhard, aces, linear
+	Exposure        float64        `json:"exposure"`           // stops applied before tone mapping
+	ProcessingError string         `json:"processing_error,omitempty"`
+	CorrectionYaw   float64        `json:"correction_yaw"` // horizon levelling applied before cube extraction
+	CorrectionPitch float64        `json:"correction_pitch"`
+	CorrectionRoll  float64        `json:"correction_roll"`
+	PipelineOptions string         `gorm:"type:text" json:"-"`   // JSON pipeline.Options used for re-slicing
+	VideoPath       string         `json:"video_path,omitempty"` // HLS master playlist, e.g. uploads/<project>/<scene>/hls/master.m3u8
+	Duration        float64        `json:"duration,omitempty"`   // seconds
+	VideoWidth      int            `json:"video_width,omitempty"`
+	VideoHeight     int            `json:"video_height,omitempty"`
+	VideoCodec      string         `json:"video_codec,omitempty"`
+	Hotspots        []Hotspot      `json:"hotspots"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Author is the public view of a user shown next to their comments
+type Author struct {
+	ID       uint   `json:"id"`
+	FullName string `json:"full_name"`
+}
+
+func (Author) TableName() string { return "users" }
+
+// Comment is feedback on a project, optionally pinned to a view in one scene
+type Comment struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID string    `gorm:"index" json:"project_id"`
+	SceneID   string    `json:"scene_id,omitempty"`
+	AuthorID  uint      `json:"author_id"`
+	Author    *Author   `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Body      string    `gorm:"type:text" json:"body"`
+	Yaw       *float64  `json:"yaw"`
+	Pitch     *float64  `json:"pitch"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Hotspot struct {
@@ -80,30 +154,112 @@ type Hotspot struct {
 }
 
 type Invitation struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Token     string         `gorm:"unique;not null" json:"token"`
-	IsUsed    bool           `gorm:"default:false" json:"is_used"`
-	IsAdmin   bool           `gorm:"default:false" json:"is_admin"`
-	ExpiresAt time.Time      `json:"expires_at"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Email          string         `gorm:"unique;not null" json:"email"`
+	Token          string         `gorm:"unique;not null" json:"token"`
+	IsUsed         bool           `gorm:"default:false" json:"is_used"`
+	IsAdmin        bool           `gorm:"default:false" json:"is_admin"`
+	PolicyID       *uint          `json:"policy_id"`
+	CohortID       *uint          `json:"cohort_id"`
+	OrganizationID *uint          `gorm:"index" json:"organization_id"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 type RegistrationCode struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Code         string         `gorm:"unique;not null" json:"code"`
-	Description  string         `json:"description"`
-	UsageCount   int            `gorm:"default:0" json:"usage_count"`
-	MaxUsage     int            `gorm:"default:0" json:"max_usage"` // 0 for unlimited
-	ProjectLimit int            `gorm:"default:3" json:"project_limit"`
-	StorageQuota int64          `json:"storage_quota"` // in bytes
-	IsActive     bool           `gorm:"default:true" json:"is_active"`
-	ValidFrom    time.Time      `json:"valid_from"`
-	ExpiresAt    time.Time      `json:"expires_at"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Code           string         `gorm:"unique;not null" json:"code"`
+	Description    string         `json:"description"`
+	UsageCount     int            `gorm:"default:0" json:"usage_count"`
+	MaxUsage       int            `gorm:"default:0" json:"max_usage"` // 0 for unlimited
+	ProjectLimit   int            `gorm:"default:3" json:"project_limit"`
+	StorageQuota   int64          `json:"storage_quota"` // in bytes
+	IsActive       bool           `gorm:"default:true" json:"is_active"`
+	PolicyID       *uint          `json:"policy_id"` // membership policy for users registering with this code
+	CohortID       *uint          `gorm:"index" json:"cohort_id"`
+	OrganizationID *uint          `gorm:"index" json:"organization_id"`
+	ValidFrom      time.Time      `json:"valid_from"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Cohort is one workshop session. Members join through its registration codes or
+// invitations; set dates override the policy so the whole cohort moves together.
+type Cohort struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Name           string         `gorm:"not null" json:"name"`
+	Description    string         `json:"description"`
+	PolicyID       *uint          `json:"policy_id"` // used when the code or invitation has none
+	OrganizationID *uint          `gorm:"index" json:"organization_id"`
+	InstructorID   *uint          `json:"instructor_id"` // user running the workshop
+	Instructor     *User          `gorm:"foreignKey:InstructorID" json:"instructor,omitempty"`
+	StartsAt       *time.Time     `json:"starts_at"`  // workshop dates, informational
+	ExpiresAt      *time.Time     `json:"expires_at"` // shared end of the creative phase
+	LocksAt        *time.Time     `json:"locks_at"`   // shared end of the view-only phase
+	IsActive       bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Organization is a tenant owning its users, cohorts and registration codes.
+// Org-admins manage only its members; its quotas apply to new registrations.
+type Organization struct {
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	Name                string         `gorm:"unique;not null" json:"name"`
+	Slug                string         `gorm:"unique;not null" json:"slug"`
+	DefaultProjectLimit int            `json:"default_project_limit"` // 0 keeps the platform default
+	DefaultStorageQuota int64          `json:"default_storage_quota"` // in bytes, 0 keeps the platform default
+	SenderName          string         `json:"sender_name"`           // sender identity for emails to members
+	SenderEmail         string         `json:"sender_email"`
+	IsActive            bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// MembershipPolicy sets how long members may create, then only view, before lockout.
+// Durations count from registration; changing a policy does not move existing users.
+type MembershipPolicy struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"unique;not null" json:"name"`
+	Description  string    `json:"description"`
+	CreativeDays int       `gorm:"default:30" json:"creative_days"`
+	ViewOnlyDays int       `gorm:"default:60" json:"view_only_days"`
+	IsDefault    bool      `gorm:"default:false" json:"is_default"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Reminder records a lifecycle email so each one is sent once per phase end date;
+// extending a membership moves the date and re-arms the reminders.
+type Reminder struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_reminder_once" json:"user_id"`
+	Phase       string    `gorm:"uniqueIndex:idx_reminder_once" json:"phase"`       // phase that is ending: creative, view_only
+	OffsetDays  int       `gorm:"uniqueIndex:idx_reminder_once" json:"offset_days"` // configured offset that triggered it
+	PhaseEndsAt time.Time `gorm:"uniqueIndex:idx_reminder_once" json:"phase_ends_at"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// ExtensionRequest is a member asking for more creative time; approval extends
+// the user's phases through the membership policy
+type ExtensionRequest struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"index" json:"user_id"`
+	User          *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Reason        string     `gorm:"type:text" json:"reason"`
+	RequestedDays int        `json:"requested_days"`
+	Status        string     `gorm:"default:'pending';index" json:"status"` // pending, approved, denied
+	GrantedDays   int        `json:"granted_days"`
+	ReviewerID    *uint      `json:"reviewer_id"`
+	ReviewNote    string     `gorm:"type:text" json:"review_note"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 type AuditLog struct {
@@ -114,3 +270,69 @@ type AuditLog struct {
 	Details   string    `gorm:"type:text" json:"details"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type Branding struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	ProjectID          *string   `gorm:"uniqueIndex" json:"project_id"` // nil for an organization or platform default
+	OrganizationID     *uint     `gorm:"index" json:"organization_id"`  // set with a nil ProjectID for the organization default
+	NadirPath          string    `json:"nadir_path"`                    // R2 key or local path of the nadir patch
+	NadirScale         float64   `gorm:"default:0.35" json:"nadir_scale"`
+	WatermarkPath      string    `json:"watermark_path"`
+	WatermarkOpacity   float64   `gorm:"default:0.6" json:"watermark_opacity"`
+	WatermarkThumbnail bool      `gorm:"default:false" json:"watermark_thumbnail"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+type BlurRegion struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SceneID   string    `gorm:"index" json:"scene_id"`
+	Shape     string    `json:"shape"`                   // rect, polygon
+	Points    string    `gorm:"type:text" json:"points"` // JSON array of {yaw, pitch} in viewer coordinates
+	Label     string    `json:"label"`                   // e.g. "Face", "Licence plate"
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session is one signed-in device. Access tokens carry its ID and stop working
+// as soon as it is revoked; the refresh token is rotated on every use.
+type Session struct {
+	ID            string     `gorm:"primaryKey;type:uuid" json:"id"`
+	UserID        uint       `gorm:"index" json:"user_id"`
+	RefreshHash   string     `gorm:"uniqueIndex" json:"-"` // sha256 of the current refresh token
+	PreviousHash  string     `gorm:"index" json:"-"`       // last rotated token, presenting it again revokes the session
+	UserAgent     string     `json:"user_agent"`
+	IP            string     `json:"ip"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"` // logout, password_reset, deactivated, demoted, ...
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// APIToken is a personal access token for scripts. Only its hash is stored; the
+// token itself is shown once, when it is created.
+type APIToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `json:"prefix"`               // first characters of the token, to tell tokens apart
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"` // sha256 of the token
+	Scopes     string     `json:"scopes"`               // comma separated: projects:read, projects:upload, admin
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode is a single-use substitute for an authenticator code. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	CodeHash  string     `gorm:"index" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
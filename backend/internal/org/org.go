@@ -0,0 +1,49 @@
+package org
+
+import (
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/mail"
+	"a360-platform/backend/internal/models"
+)
+
+// Find returns the active organization with the given ID, nil when there is none
+func Find(db *gorm.DB, id *uint) *models.Organization {
+	if id == nil {
+		return nil
+	}
+	var o models.Organization
+	if db.Where("is_active = ?", true).First(&o, *id).Error != nil {
+		return nil
+	}
+	return &o
+}
+
+// Sender returns the email identity for members of an organization, falling back
+// to the platform identity for any part the organization leaves unset
+func Sender(db *gorm.DB, id *uint) mail.Sender {
+	sender := mail.DefaultSender
+	if o := Find(db, id); o != nil {
+		if o.SenderName != "" {
+			sender.Name = o.SenderName
+		}
+		if o.SenderEmail != "" {
+			sender.Email = o.SenderEmail
+		}
+	}
+	return sender
+}
+
+// ApplyQuotas gives a new member the organization's default quotas where it sets them
+func ApplyQuotas(user *models.User, o *models.Organization) {
+	if o == nil {
+		return
+	}
+	user.OrganizationID = &o.ID
+	if o.DefaultProjectLimit > 0 {
+		user.ProjectLimit = o.DefaultProjectLimit
+	}
+	if o.DefaultStorageQuota > 0 {
+		user.StorageQuota = o.DefaultStorageQuota
+	}
+}
@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	BlurRect    = "rect"
+	BlurPolygon = "polygon"
+)
+
+// YawPitch is a position on the sphere in degrees, as used by hotspots
+type YawPitch struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+}
+
+// BlurRegion is an area of the viewed sphere to redact. A rect is given by two
+// opposite corners and spans yaw from the first to the second, wrapping at ±180.
+// Normalize keeps that span under 180°, anything wider has to be a polygon.
+type BlurRegion struct {
+	Shape  string     `json:"shape"`
+	Points []YawPitch `json:"points"`
+}
+
+// Valid reports whether the region has enough points for its shape
+func (r BlurRegion) Valid() bool {
+	switch r.Shape {
+	case BlurRect:
+		return len(r.Points) == 2
+	case BlurPolygon:
+		return len(r.Points) >= 3
+	}
+	return false
+}
+
+// Normalize orders the corners of a rect so it spans the shorter way round in
+// yaw; corners picked right to left would otherwise cover nearly the whole sphere
+func (r BlurRegion) Normalize() BlurRegion {
+	if r.Shape != BlurRect || len(r.Points) != 2 {
+		return r
+	}
+	if math.Mod(r.Points[1].Yaw-r.Points[0].Yaw+360, 360) > 180 {
+		a, b := r.Points[0], r.Points[1]
+		r.Points = []YawPitch{{Yaw: b.Yaw, Pitch: a.Pitch}, {Yaw: a.Yaw, Pitch: b.Pitch}}
+	}
+	return r
+}
+
+// rectEdgeSteps is how many segments each rect edge becomes when a rect has to be turned into a polygon
+const rectEdgeSteps = 8
+
+// Reorient moves the region from viewer coordinates under one correction to
+// another, like Reorient does for a single point. A rect stays a rect while both
+// corrections are pure yaw; any tilt bends its edges, so it becomes a polygon
+// tracing its outline.
+func (r BlurRegion) Reorient(from, to Orientation) BlurRegion {
+	if r.Shape == BlurRect && len(r.Points) == 2 && !(levelOrientation(from) && levelOrientation(to)) {
+		r = r.Normalize()
+		a, b := r.Points[0], r.Points[1]
+		span := math.Mod(b.Yaw-a.Yaw+360, 360)
+		var outline []YawPitch
+		for _, edge := range [][2]YawPitch{
+			{{a.Yaw, a.Pitch}, {a.Yaw + span, a.Pitch}},
+			{{a.Yaw + span, a.Pitch}, {a.Yaw + span, b.Pitch}},
+			{{a.Yaw + span, b.Pitch}, {a.Yaw, b.Pitch}},
+			{{a.Yaw, b.Pitch}, {a.Yaw, a.Pitch}},
+		} {
+			for i := 0; i < rectEdgeSteps; i++ {
+				t := float64(i) / rectEdgeSteps
+				outline = append(outline, YawPitch{
+					Yaw:   wrapDegrees(edge[0].Yaw + (edge[1].Yaw-edge[0].Yaw)*t),
+					Pitch: edge[0].Pitch + (edge[1].Pitch-edge[0].Pitch)*t,
+				})
+			}
+		}
+		r = BlurRegion{Shape: BlurPolygon, Points: outline}
+	}
+
+	points := make([]YawPitch, len(r.Points))
+	for i, p := range r.Points {
+		points[i].Yaw, points[i].Pitch = Reorient(p.Yaw, p.Pitch, from, to)
+	}
+	r.Points = points
+	return r
+}
+
+func levelOrientation(o Orientation) bool {
+	return o.Pitch == 0 && o.Roll == 0
+}
+
+// contains reports whether yaw/pitch (degrees) lies inside the region
+func (r BlurRegion) contains(yaw, pitch float64) bool {
+	ref := r.Points[0].Yaw
+	rel := func(y float64) float64 { return wrapDegrees(y - ref) }
+
+	if r.Shape == BlurRect {
+		span := math.Mod(r.Points[1].Yaw-ref+360, 360)
+		dy := math.Mod(yaw-ref+360, 360)
+		lo, hi := math.Min(r.Points[0].Pitch, r.Points[1].Pitch), math.Max(r.Points[0].Pitch, r.Points[1].Pitch)
+		return dy <= span && pitch >= lo && pitch <= hi
+	}
+
+	// Even-odd rule in yaw/pitch space, with yaw unwrapped around the first point
+	x, y := rel(yaw), pitch
+	inside := false
+	n := len(r.Points)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		xi, yi := rel(r.Points[i].Yaw), r.Points[i].Pitch
+		xj, yj := rel(r.Points[j].Yaw), r.Points[j].Pitch
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func wrapDegrees(d float64) float64 {
+	return math.Mod(d+540, 360) - 180
+}
+
+// ApplyBlur redacts regions on an equirectangular image. Regions are in viewer
+// coordinates, so rot (the rotation applied at cube extraction) maps them back
+// onto the source pixels.
+func ApplyBlur(src image.Image, regions []BlurRegion, rot Orientation) image.Image {
+	var valid []BlurRegion
+	for _, r := range regions {
+		if r.Valid() {
+			valid = append(valid, r)
+		}
+	}
+	if len(valid) == 0 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	sigma := math.Max(4, float64(w)/150)
+	blurred := imaging.Blur(src, sigma)
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+
+	inv := rot.matrix().transpose()
+	for y := 0; y < h; y++ {
+		pitch := 90 - (float64(y)+0.5)/float64(h)*180
+		for x := 0; x < w; x++ {
+			yaw := (float64(x)+0.5)/float64(w)*360 - 180
+			vyaw, vpitch := yaw, pitch
+			if !rot.IsZero() {
+				vyaw, vpitch = angles(inv.apply(direction(yaw, pitch)))
+			}
+			for _, r := range valid {
+				if r.contains(vyaw, vpitch) {
+					dst.Set(x, y, blurred.At(x, y))
+					break
+				}
+			}
+		}
+	}
+	return dst
+}
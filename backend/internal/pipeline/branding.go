@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Branding overlays institution artwork on derived images
+type Branding struct {
+	Nadir      image.Image // patched over the tripod in the middle of the negy face
+	NadirScale float64     // fraction of the face width the nadir patch covers, 0.35 by default
+
+	Watermark          image.Image // composited in the bottom-right corner
+	WatermarkOpacity   float64     // 0..1, 0.6 by default
+	WatermarkThumbnail bool        // also watermark the scene thumbnails, side faces and preview always are
+}
+
+// ApplyNadir centres the nadir patch on a bottom (negy) face
+func ApplyNadir(face image.Image, b *Branding) image.Image {
+	if b == nil || b.Nadir == nil {
+		return face
+	}
+	scale := b.NadirScale
+	if scale <= 0 || scale > 1 {
+		scale = 0.35
+	}
+	size := int(float64(face.Bounds().Dx()) * scale)
+	if size < 1 {
+		return face
+	}
+	patch := imaging.Fit(b.Nadir, size, size, imaging.Lanczos)
+	pos := image.Pt((face.Bounds().Dx()-patch.Bounds().Dx())/2, (face.Bounds().Dy()-patch.Bounds().Dy())/2)
+	return imaging.Overlay(face, patch, pos, 1.0)
+}
+
+// ApplyWatermark places the watermark in the bottom-right corner at a fifth of the image width
+func ApplyWatermark(img image.Image, b *Branding) image.Image {
+	if b == nil || b.Watermark == nil {
+		return img
+	}
+	opacity := b.WatermarkOpacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 0.6
+	}
+	w := img.Bounds().Dx() / 5
+	if w < 1 {
+		return img
+	}
+	mark := imaging.Resize(b.Watermark, w, 0, imaging.Lanczos)
+	margin := img.Bounds().Dx() / 40
+	pos := image.Pt(img.Bounds().Dx()-mark.Bounds().Dx()-margin, img.Bounds().Dy()-mark.Bounds().Dy()-margin)
+	return imaging.Overlay(img, mark, pos, opacity)
+}
@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+// BundleFile is the manifest written at the root of an offline slicing run
+const BundleFile = "bundle.json"
+
+// BundleVersion is bumped whenever the bundle layout changes incompatibly
+const BundleVersion = 1
+
+// Bundle describes a folder of scenes sliced outside the server, laid out the
+// same way the server lays out uploads/<project>/<scene>
+type Bundle struct {
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"created_at"`
+	Scenes    []BundleScene `json:"scenes"`
+}
+
+// BundleScene is one sliced panorama. Everything the server would store on the
+// scene after slicing it itself is kept, so importing does not need to re-slice.
+type BundleScene struct {
+	Name     string    `json:"name"`
+	Source   string    `json:"source"`             // input file name
+	Dir      string    `json:"dir"`                // scene directory relative to the bundle root
+	Original string    `json:"original,omitempty"` // original file in Dir, original.jpg unless HDR
+	Error    string    `json:"error,omitempty"`    // set when slicing failed, the scene has no output
+	Options  Options   `json:"options"`            // as resolved, so the server can re-slice identically
+	Manifest Manifest  `json:"manifest"`
+	Coverage Coverage  `json:"coverage"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	InitialYaw   float64 `json:"initial_yaw"`
+	InitialPitch float64 `json:"initial_pitch"`
+}
+
+// NewBundleScene records res, written below sceneDir, together with the options it was sliced with
+func NewBundleScene(res *Result, sceneDir string, opts Options) BundleScene {
+	// Remember what was resolved so a re-slice reproduces this result
+	opts.Stereo = res.Stereo
+	opts.Projection = res.Projection
+	opts.ToneMap = res.ToneMap
+	opts.Rotation = res.Rotation
+	opts.AutoLevel = false
+
+	return BundleScene{
+		Options:      opts,
+		Manifest:     res.Manifest(sceneDir),
+		Coverage:     res.Coverage,
+		Metadata:     res.Metadata,
+		InitialYaw:   res.InitialYaw,
+		InitialPitch: res.InitialPitch,
+	}
+}
+
+// OriginalFile is the name of the original inside the scene directory
+func (s BundleScene) OriginalFile() string {
+	if s.Original != "" {
+		return s.Original
+	}
+	return "original.jpg"
+}
+
+// Files lists every file of the scene relative to its directory, the original included
+func (s BundleScene) Files() []string {
+	m := s.Manifest
+	files := []string{s.OriginalFile()}
+	files = append(files, m.Faces...)
+	files = append(files, m.RightFaces...)
+	for _, p := range []string{m.Thumbnail, m.Preview} {
+		if p != "" {
+			files = append(files, p)
+		}
+	}
+	for _, p := range m.Thumbnails {
+		files = append(files, p)
+	}
+	for _, vs := range m.Variants {
+		for _, v := range vs {
+			files = append(files, v.Path)
+		}
+	}
+	return files
+}
+
+// ReadBundle loads and validates a bundle manifest
+func ReadBundle(file string) (*Bundle, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", BundleFile, err)
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	for _, s := range b.Scenes {
+		if !localPath(s.Dir) {
+			return nil, fmt.Errorf("scene %q has an invalid directory %q", s.Name, s.Dir)
+		}
+		for _, f := range s.Files() {
+			if !localPath(f) {
+				return nil, fmt.Errorf("scene %q references a file outside its directory: %q", s.Name, f)
+			}
+		}
+	}
+	return &b, nil
+}
+
+// CheckAsset verifies that file really holds the image type its extension
+// declares, so an imported bundle cannot smuggle markup or scripts in as scene assets
+func CheckAsset(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := filepath.Base(file)
+	ext := strings.ToLower(filepath.Ext(file))
+	switch ext {
+	case ".jpg", ".jpeg", ".png":
+		_, format, err := image.DecodeConfig(f)
+		if err != nil || (format == "png") != (ext == ".png") {
+			return fmt.Errorf("%s is not a valid %s image", name, strings.TrimPrefix(ext, "."))
+		}
+		return nil
+	case ".webp":
+		if _, err := webp.DecodeConfig(f); err != nil {
+			return fmt.Errorf("%s is not a valid webp image", name)
+		}
+		return nil
+	}
+
+	head := make([]byte, 12)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	switch ext {
+	case ".avif":
+		if n == 12 && string(head[4:8]) == "ftyp" && (string(head[8:12]) == "avif" || string(head[8:12]) == "avis") {
+			return nil
+		}
+	case ".hdr":
+		if bytes.HasPrefix(head, []byte("#?RADIANCE")) || bytes.HasPrefix(head, []byte("#?RGBE")) {
+			return nil
+		}
+	case ".exr":
+		if bytes.HasPrefix(head, []byte{0x76, 0x2f, 0x31, 0x01}) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%s has an unsupported file type", name)
+	}
+	return fmt.Errorf("%s is not a valid %s image", name, strings.TrimPrefix(ext, "."))
+}
+
+// Write stores the bundle manifest as indented JSON
+func (b *Bundle) Write(file string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// localPath reports whether p is a relative slash path that stays below its base
+func localPath(p string) bool {
+	if p == "" || strings.Contains(p, "\\") || path.IsAbs(p) {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
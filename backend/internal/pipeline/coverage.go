@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProjectionEquirect    = "equirectangular"
+	ProjectionCylindrical = "cylindrical"
+)
+
+// maxPaddedWidth keeps tiny field-of-view inputs from blowing up into huge canvases
+const maxPaddedWidth = 16384
+
+// Coverage is the part of the sphere an input image covers, in degrees
+type Coverage struct {
+	HFov     float64 // horizontal field of view
+	VFov     float64 // vertical field of view
+	LeftYaw  float64 // yaw at the left edge, -180 is the back of the sphere
+	TopPitch float64 // pitch at the top edge, 90 is straight up
+}
+
+// FullSphere reports whether c covers the whole sphere
+func (c Coverage) FullSphere() bool {
+	return c.HFov >= 359.9 && c.VFov >= 179.9
+}
+
+// MinPitch and MaxPitch are the valid pitch range a viewer should clamp to
+func (c Coverage) MinPitch() float64 { return c.TopPitch - c.VFov }
+func (c Coverage) MaxPitch() float64 { return c.TopPitch }
+
+// FullCoverage is what a regular 2:1 equirectangular covers
+var FullCoverage = Coverage{HFov: 360, VFov: 180, LeftYaw: -180, TopPitch: 90}
+
+// ResolveCoverage works out what an image of size b covers. Explicit field of
+// view options win over GPano cropped-area tags; without either a 2:1 image is
+// a full sphere and anything wider is treated as a 360° strip centred on the horizon.
+func ResolveCoverage(b image.Rectangle, opts Options, gp *GPano) Coverage {
+	w, h := float64(b.Dx()), float64(b.Dy())
+	if w == 0 || h == 0 {
+		return FullCoverage
+	}
+
+	if opts.HFov == 0 && opts.VFov == 0 && gp != nil && gp.HasCrop() {
+		fw, fh := float64(gp.FullPanoWidthPixels), float64(gp.FullPanoHeightPixels)
+		return Coverage{
+			HFov:     float64(gp.CroppedAreaImageWidthPixels) / fw * 360,
+			VFov:     float64(gp.CroppedAreaImageHeightPixels) / fh * 180,
+			LeftYaw:  float64(gp.CroppedAreaLeftPixels)/fw*360 - 180,
+			TopPitch: 90 - float64(gp.CroppedAreaTopPixels)/fh*180,
+		}
+	}
+
+	hfov, vfov := opts.HFov, opts.VFov
+	if hfov <= 0 || hfov > 360 {
+		hfov = 360
+	}
+	if vfov <= 0 || vfov > 180 {
+		if opts.Projection == ProjectionCylindrical {
+			f := w / (hfov * math.Pi / 180)
+			vfov = 2 * math.Atan(h/2/f) * 180 / math.Pi
+		} else {
+			vfov = math.Min(180, hfov*h/w)
+		}
+	}
+	return Coverage{HFov: hfov, VFov: vfov, LeftYaw: -hfov / 2, TopPitch: vfov / 2}
+}
+
+// PadToSphere places a partial panorama on a full 2:1 equirectangular canvas,
+// filling everything it does not cover with fill. Cylindrical input is remapped
+// vertically so straight rows stay straight after projection.
+func PadToSphere(src image.Image, c Coverage, projection string, fill color.Color) image.Image {
+	b := src.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	fullW := int(math.Round(w * 360 / c.HFov))
+	if fullW > maxPaddedWidth {
+		fullW = maxPaddedWidth
+	}
+	fullW -= fullW % 2
+	fullH := fullW / 2
+	dst := image.NewRGBA(image.Rect(0, 0, fullW, fullH))
+
+	// Focal length in pixels for the cylindrical case
+	f := w / (c.HFov * math.Pi / 180)
+	centerPitch := c.TopPitch - c.VFov/2
+
+	for y := 0; y < fullH; y++ {
+		pitch := 90 - (float64(y)+0.5)/float64(fullH)*180
+		dy := c.TopPitch - pitch
+		sy := -1.0
+		if dy >= 0 && dy < c.VFov {
+			if projection == ProjectionCylindrical {
+				sy = h/2 - math.Tan((pitch-centerPitch)*math.Pi/180)*f
+			} else {
+				sy = dy / c.VFov * h
+			}
+		}
+
+		for x := 0; x < fullW; x++ {
+			yaw := (float64(x)+0.5)/float64(fullW)*360 - 180
+			dx := math.Mod(yaw-c.LeftYaw+720, 360)
+			if sy < 0 || sy >= h || dx >= c.HFov {
+				dst.Set(x, y, fill)
+				continue
+			}
+			sx := dx / c.HFov * w
+			dst.Set(x, y, src.At(b.Min.X+int(sx), b.Min.Y+int(sy)))
+		}
+	}
+	return dst
+}
+
+// ParseHexColor parses "#rrggbb" or "rrggbb"
+func ParseHexColor(s string) (color.Color, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
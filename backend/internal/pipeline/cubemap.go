@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// Input layouts for uploads that are not an equirectangular panorama yet
+const (
+	LayoutEquirect = "equirect" // default
+	LayoutCubemap  = "cubemap"  // one image holding all faces as a cross or strip
+	LayoutFaces    = "faces"    // six separate face images in FaceNames order
+)
+
+// IsLayout reports whether layout is one of the supported input layouts
+func IsLayout(layout string) bool {
+	switch layout {
+	case "", LayoutEquirect, LayoutCubemap, LayoutFaces:
+		return true
+	}
+	return false
+}
+
+// Cells of each face in a horizontal cross (4×3). The vertical cross (3×4) uses the
+// same arrangement for the top three rows and keeps the back face upside down below.
+//
+//	      posy
+//	negx  posz  posx  negz
+//	      negy
+var crossCells = [6]image.Point{{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {3, 1}}
+var verticalCrossCells = [6]image.Point{{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {1, 3}}
+
+// SplitCubemap cuts a single cubemap image into its faces in FaceNames order.
+// Horizontal (4:3) and vertical (3:4) crosses and 6:1 / 1:6 strips in FaceNames order are recognised.
+func SplitCubemap(src image.Image) ([]image.Image, error) {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	var cells [6]image.Point
+	var size int
+	switch {
+	case w*3 == h*4:
+		cells, size = crossCells, w/4
+	case w*4 == h*3:
+		cells, size = verticalCrossCells, w/3
+	case w == h*6:
+		for i := range cells {
+			cells[i] = image.Pt(i, 0)
+		}
+		size = h
+	case h == w*6:
+		for i := range cells {
+			cells[i] = image.Pt(0, i)
+		}
+		size = w
+	default:
+		return nil, fmt.Errorf("unrecognised cubemap layout %dx%d, expected a 4:3 or 3:4 cross or a 6:1 or 1:6 strip", w, h)
+	}
+
+	faces := make([]image.Image, 6)
+	for i, cell := range cells {
+		r := image.Rect(cell.X*size, cell.Y*size, (cell.X+1)*size, (cell.Y+1)*size).Add(b.Min)
+		faces[i] = imaging.Crop(src, r)
+	}
+	if cells == verticalCrossCells {
+		faces[5] = imaging.Rotate180(faces[5])
+	}
+	return faces, nil
+}
+
+// CubeToEquirect reconstructs a 2:1 equirectangular panorama from six square faces in
+// FaceNames order. It is the inverse of ExtractFace, so re-slicing the result gives the faces back.
+func CubeToEquirect(faces []image.Image) (*image.NRGBA, error) {
+	if len(faces) != len(FaceNames) {
+		return nil, fmt.Errorf("expected %d cube faces, got %d", len(FaceNames), len(faces))
+	}
+	size := faces[0].Bounds().Dx()
+	for i, f := range faces {
+		if f.Bounds().Dx() != f.Bounds().Dy() {
+			return nil, fmt.Errorf("cube face %s is not square", FaceNames[i])
+		}
+		if f.Bounds().Dx() != size {
+			return nil, errors.New("cube faces must all have the same size")
+		}
+	}
+	if size == 0 {
+		return nil, errors.New("cube faces are empty")
+	}
+
+	w, h := size*4, size*2
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		pitch := 90 - (float64(y)+0.5)/float64(h)*180
+		for x := 0; x < w; x++ {
+			yaw := (float64(x)+0.5)/float64(w)*360 - 180
+			face, u, v := cubeFace(direction(yaw, pitch))
+
+			src := faces[face]
+			fx := clampInt(int((u+1)/2*float64(size)), 0, size-1)
+			fy := clampInt(int((v+1)/2*float64(size)), 0, size-1)
+			dst.Set(x, y, src.At(src.Bounds().Min.X+fx, src.Bounds().Min.Y+fy))
+		}
+	}
+	return dst, nil
+}
+
+// cubeFace finds the face a direction hits and the face coordinates in [-1, 1],
+// mirroring the face orientation used by ExtractFaceRotated
+func cubeFace(x, y, z float64) (face int, u, v float64) {
+	ax, ay, az := math.Abs(x), math.Abs(y), math.Abs(z)
+	switch {
+	case ax >= ay && ax >= az && x > 0:
+		return 0, -z / ax, -y / ax
+	case ax >= ay && ax >= az:
+		return 1, z / ax, -y / ax
+	case ay >= az && y > 0:
+		return 2, x / ay, z / ay
+	case ay >= az:
+		return 3, x / ay, -z / ay
+	case z > 0:
+		return 4, x / az, -y / az
+	default:
+		return 5, -x / az, -y / az
+	}
+}
+
+// AssembleEquirect writes the equirectangular JPEG for an upload in a cube layout:
+// one path for LayoutCubemap, six paths in FaceNames order for LayoutFaces
+func AssembleEquirect(paths []string, layout, outputPath string) error {
+	var faces []image.Image
+	switch layout {
+	case LayoutCubemap:
+		if len(paths) != 1 {
+			return errors.New("cubemap layout expects a single image")
+		}
+		src, err := imaging.Open(paths[0])
+		if err != nil {
+			return err
+		}
+		if faces, err = SplitCubemap(src); err != nil {
+			return err
+		}
+	case LayoutFaces:
+		for _, p := range paths {
+			face, err := imaging.Open(p)
+			if err != nil {
+				return err
+			}
+			faces = append(faces, face)
+		}
+	default:
+		return fmt.Errorf("layout %q needs no assembly", layout)
+	}
+
+	equirect, err := CubeToEquirect(faces)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, equirect, &jpeg.Options{Quality: 95}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,203 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+	FormatAVIF = "avif"
+)
+
+// Profile is one encoded variant of an output image
+type Profile struct {
+	Format      string `json:"format"`
+	Quality     int    `json:"quality"`
+	Progressive bool   `json:"progressive,omitempty"` // JPEG only, needs jpegtran
+}
+
+// Variant is an encoded file written for a Profile
+type Variant struct {
+	Format string `json:"format"`
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// DefaultProfiles is used when no profiles are configured
+var DefaultProfiles = []Profile{{Format: FormatJPEG, Quality: 85}}
+
+var formatExtensions = map[string]string{FormatJPEG: ".jpg", FormatWebP: ".webp", FormatAVIF: ".avif"}
+
+// ParseProfiles reads a list like "jpeg:85:progressive,webp:80,avif:60"
+func ParseProfiles(s string) ([]Profile, error) {
+	var profiles []Profile
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		p := Profile{Format: strings.ToLower(fields[0]), Quality: 85}
+		if _, ok := formatExtensions[p.Format]; !ok {
+			return nil, fmt.Errorf("unknown image format %q", fields[0])
+		}
+		if len(fields) > 1 {
+			q, err := strconv.Atoi(fields[1])
+			if err != nil || q < 1 || q > 100 {
+				return nil, fmt.Errorf("invalid quality in %q", part)
+			}
+			p.Quality = q
+		}
+		if len(fields) > 2 && fields[2] == "progressive" {
+			p.Progressive = true
+		}
+		profiles = append(profiles, p)
+	}
+	return NormalizeProfiles(profiles), nil
+}
+
+// NormalizeProfiles keeps one profile per format and makes sure a JPEG fallback comes first
+func NormalizeProfiles(profiles []Profile) []Profile {
+	seen := map[string]bool{}
+	var jpeg *Profile
+	var others []Profile
+	for _, p := range profiles {
+		if seen[p.Format] {
+			continue
+		}
+		seen[p.Format] = true
+		if p.Format == FormatJPEG {
+			p := p
+			jpeg = &p
+		} else {
+			others = append(others, p)
+		}
+	}
+	if jpeg == nil {
+		jpeg = &DefaultProfiles[0]
+	}
+	return append([]Profile{*jpeg}, others...)
+}
+
+// CheckEncoders reports the first profile whose command line encoder is not installed,
+// so a misconfigured format fails at startup instead of on every scene
+func CheckEncoders(profiles []Profile) error {
+	for _, p := range profiles {
+		var envVar, name string
+		switch {
+		case p.Format == FormatWebP:
+			envVar, name = "CWEBP_PATH", "cwebp"
+		case p.Format == FormatAVIF:
+			envVar, name = "AVIFENC_PATH", "avifenc"
+		case p.Format == FormatJPEG && p.Progressive:
+			envVar, name = "JPEGTRAN_PATH", "jpegtran"
+		default:
+			continue
+		}
+		if encoderPath(envVar, name) == "" {
+			return fmt.Errorf("%s output needs %s, install it or set %s", p.Format, name, envVar)
+		}
+	}
+	return nil
+}
+
+// SaveVariants encodes img once per profile next to basePath (a path without extension).
+// The JPEG is always written and returned first. Any profile that cannot be encoded
+// fails the whole call, a configured format is never silently left out.
+func SaveVariants(img image.Image, basePath string, profiles []Profile) ([]Variant, error) {
+	if len(profiles) == 0 {
+		profiles = DefaultProfiles
+	}
+	profiles = NormalizeProfiles(profiles)
+
+	var variants []Variant
+	for _, p := range profiles {
+		path := basePath + formatExtensions[p.Format]
+		var err error
+		switch p.Format {
+		case FormatJPEG:
+			err = saveJPEG(img, path, p)
+		case FormatWebP:
+			err = encodeExternal(img, path, "CWEBP_PATH", "cwebp", func(in, out string) []string {
+				return []string{"-quiet", "-q", strconv.Itoa(p.Quality), in, "-o", out}
+			})
+		case FormatAVIF:
+			err = encodeExternal(img, path, "AVIFENC_PATH", "avifenc", func(in, out string) []string {
+				return []string{"-q", strconv.Itoa(p.Quality), in, out}
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", p.Format, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{Format: p.Format, Path: path, Bytes: info.Size()})
+	}
+	return variants, nil
+}
+
+func saveJPEG(img image.Image, path string, p Profile) error {
+	if err := imaging.Save(img, path, imaging.JPEGQuality(p.Quality)); err != nil {
+		return err
+	}
+	if !p.Progressive {
+		return nil
+	}
+
+	// The standard library only writes baseline JPEGs, so convert losslessly with jpegtran
+	bin := encoderPath("JPEGTRAN_PATH", "jpegtran")
+	if bin == "" {
+		return fmt.Errorf("jpegtran not found")
+	}
+	tmp := path + ".progressive"
+	if out, err := exec.Command(bin, "-copy", "none", "-optimize", "-progressive", "-outfile", tmp, path).CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("jpegtran: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.Rename(tmp, path)
+}
+
+// encodeExternal hands img to a bundled command line encoder through a temporary PNG
+func encodeExternal(img image.Image, path, envVar, name string, args func(in, out string) []string) error {
+	bin := encoderPath(envVar, name)
+	if bin == "" {
+		return fmt.Errorf("%s not found", name)
+	}
+
+	tmp, err := os.CreateTemp("", "variant-*.png")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := imaging.Save(img, tmp.Name()); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command(bin, args(tmp.Name(), path)...).CombinedOutput(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func encoderPath(envVar, name string) string {
+	if p := os.Getenv(envVar); p != "" {
+		return p
+	}
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return ""
+	}
+	return p
+}
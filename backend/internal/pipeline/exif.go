@@ -0,0 +1,225 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// TIFF/EXIF tags read by ReadMetadata
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagOffsetTimeOrig   = 0x9011
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+	tagGPSAltitudeRef   = 0x0005
+	tagGPSAltitude      = 0x0006
+)
+
+// Metadata is what we keep from the EXIF and XMP headers of an uploaded panorama
+type Metadata struct {
+	CapturedAt  *time.Time
+	CameraMake  string
+	CameraModel string
+	Latitude    *float64
+	Longitude   *float64
+	Altitude    *float64
+	GPano       *GPano
+}
+
+// ReadMetadata parses the EXIF and XMP GPano headers of a JPEG file.
+// Files without headers (or that are not JPEGs) return empty metadata.
+func ReadMetadata(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	md := &Metadata{}
+	err = walkJPEGSegments(f, func(marker byte, data []byte) bool {
+		if marker != 0xE1 {
+			return true
+		}
+		switch {
+		case bytes.HasPrefix(data, exifHeader):
+			md.readExif(data[len(exifHeader):])
+		case bytes.HasPrefix(data, xmpHeader):
+			md.GPano = ParseGPano(string(data[len(xmpHeader):]))
+		}
+		return true
+	})
+	return md, err
+}
+
+func (md *Metadata) readExif(b []byte) {
+	t, err := newTIFF(b)
+	if err != nil {
+		return
+	}
+	ifd0, _ := t.readIFD(t.firstIFD())
+
+	md.CameraMake = t.ascii(ifd0[tagMake])
+	md.CameraModel = t.ascii(ifd0[tagModel])
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		exif, _ := t.readIFD(int(t.long(e)))
+		if s := t.ascii(exif[tagDateTimeOriginal]); s != "" {
+			loc := time.Local
+			if off := t.ascii(exif[tagOffsetTimeOrig]); off != "" {
+				if o, err := time.Parse("-07:00", off); err == nil {
+					loc = o.Location()
+				}
+			}
+			if ts, err := time.ParseInLocation("2006:01:02 15:04:05", s, loc); err == nil {
+				md.CapturedAt = &ts
+			}
+		}
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		gps, _ := t.readIFD(int(t.long(e)))
+		if lat, ok := t.degrees(gps[tagGPSLatitude]); ok {
+			if strings.HasPrefix(t.ascii(gps[tagGPSLatitudeRef]), "S") {
+				lat = -lat
+			}
+			md.Latitude = &lat
+		}
+		if lon, ok := t.degrees(gps[tagGPSLongitude]); ok {
+			if strings.HasPrefix(t.ascii(gps[tagGPSLongitudeRef]), "W") {
+				lon = -lon
+			}
+			md.Longitude = &lon
+		}
+		if alt, ok := t.rational(gps[tagGPSAltitude], 0); ok {
+			if ref, ok := gps[tagGPSAltitudeRef]; ok && t.b[ref.valueOffset] == 1 {
+				alt = -alt
+			}
+			md.Altitude = &alt
+		}
+	}
+}
+
+// tiff is a minimal reader for the TIFF structure inside an EXIF segment
+type tiff struct {
+	b  []byte
+	bo binary.ByteOrder
+}
+
+type tiffEntry struct {
+	tag         uint16
+	typ         uint16
+	count       uint32
+	valueOffset int // absolute offset of the value bytes within b
+}
+
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+func newTIFF(b []byte) (*tiff, error) {
+	if len(b) < 8 {
+		return nil, errors.New("short TIFF header")
+	}
+	t := &tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.bo = binary.LittleEndian
+	case "MM":
+		t.bo = binary.BigEndian
+	default:
+		return nil, errors.New("invalid TIFF byte order")
+	}
+	return t, nil
+}
+
+func (t *tiff) firstIFD() int {
+	return int(t.bo.Uint32(t.b[4:8]))
+}
+
+func (t *tiff) readIFD(offset int) (map[uint16]tiffEntry, error) {
+	entries := map[uint16]tiffEntry{}
+	if offset <= 0 || offset+2 > len(t.b) {
+		return entries, errors.New("IFD out of range")
+	}
+	n := int(t.bo.Uint16(t.b[offset:]))
+	for i := 0; i < n; i++ {
+		p := offset + 2 + i*12
+		if p+12 > len(t.b) {
+			return entries, errors.New("IFD entry out of range")
+		}
+		e := tiffEntry{
+			tag:   t.bo.Uint16(t.b[p:]),
+			typ:   t.bo.Uint16(t.b[p+2:]),
+			count: t.bo.Uint32(t.b[p+4:]),
+		}
+		size := tiffTypeSizes[e.typ] * int(e.count)
+		if size <= 4 {
+			e.valueOffset = p + 8
+		} else {
+			e.valueOffset = int(t.bo.Uint32(t.b[p+8:]))
+		}
+		if size == 0 || e.valueOffset+size > len(t.b) {
+			continue
+		}
+		entries[e.tag] = e
+	}
+	return entries, nil
+}
+
+func (t *tiff) size(e tiffEntry) int {
+	return tiffTypeSizes[e.typ] * int(e.count)
+}
+
+func (t *tiff) ascii(e tiffEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	s := t.b[e.valueOffset : e.valueOffset+t.size(e)]
+	return strings.TrimSpace(strings.TrimRight(string(s), "\x00"))
+}
+
+func (t *tiff) long(e tiffEntry) uint32 {
+	switch e.typ {
+	case 3:
+		return uint32(t.bo.Uint16(t.b[e.valueOffset:]))
+	case 4, 9:
+		return t.bo.Uint32(t.b[e.valueOffset:])
+	}
+	return 0
+}
+
+func (t *tiff) rational(e tiffEntry, i int) (float64, bool) {
+	if (e.typ != 5 && e.typ != 10) || uint32(i) >= e.count {
+		return 0, false
+	}
+	p := e.valueOffset + i*8
+	num, den := t.bo.Uint32(t.b[p:]), t.bo.Uint32(t.b[p+4:])
+	if den == 0 {
+		return 0, false
+	}
+	if e.typ == 10 {
+		return float64(int32(num)) / float64(int32(den)), true
+	}
+	return float64(num) / float64(den), true
+}
+
+// degrees converts a GPS degrees/minutes/seconds triple to decimal degrees
+func (t *tiff) degrees(e tiffEntry) (float64, bool) {
+	d, ok1 := t.rational(e, 0)
+	m, ok2 := t.rational(e, 1)
+	s, ok3 := t.rational(e, 2)
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	return d + m/60 + s/3600, true
+}
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// OpenEXR support covers what renderers export for environment maps: single part
+// scanline files with NONE, RLE, ZIPS or ZIP compression and HALF or FLOAT channels.
+const (
+	exrNoCompression = 0
+	exrRLE           = 1
+	exrZIPS          = 2
+	exrZIP           = 3
+
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+)
+
+type exrChannel struct {
+	name      string
+	pixelType int32
+}
+
+func (c exrChannel) size() int {
+	if c.pixelType == exrHalf {
+		return 2
+	}
+	return 4
+}
+
+// DecodeEXR reads an OpenEXR image. Tiled, multi-part and deep files as well as the
+// lossy/wavelet compressions (PIZ, PXR24, B44, DWA) are rejected with an error.
+func DecodeEXR(r io.Reader) (*HDRImage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != 20000630 {
+		return nil, errors.New("not an OpenEXR file")
+	}
+	if flags := binary.LittleEndian.Uint32(data[4:]); flags&(0x200|0x800|0x1000) != 0 {
+		return nil, errors.New("only single part scanline OpenEXR files are supported")
+	}
+
+	p := 8
+	var channels []exrChannel
+	compression := -1
+	var xMin, yMin, xMax, yMax int32
+	haveWindow := false
+	for {
+		name, ok := exrString(data, &p)
+		if !ok {
+			return nil, errors.New("exr: truncated header")
+		}
+		if name == "" {
+			break
+		}
+		typ, ok := exrString(data, &p)
+		if !ok || p+4 > len(data) {
+			return nil, errors.New("exr: truncated header")
+		}
+		size := int(binary.LittleEndian.Uint32(data[p:]))
+		p += 4
+		if size < 0 || p+size > len(data) {
+			return nil, errors.New("exr: truncated header")
+		}
+		value := data[p : p+size]
+		p += size
+
+		switch {
+		case name == "channels" && typ == "chlist":
+			for q := 0; ; {
+				chName, ok := exrString(value, &q)
+				if !ok {
+					return nil, errors.New("exr: bad channel list")
+				}
+				if chName == "" {
+					break
+				}
+				if q+16 > len(value) {
+					return nil, errors.New("exr: bad channel list")
+				}
+				pixelType := int32(binary.LittleEndian.Uint32(value[q:]))
+				xs, ys := binary.LittleEndian.Uint32(value[q+8:]), binary.LittleEndian.Uint32(value[q+12:])
+				if xs != 1 || ys != 1 {
+					return nil, errors.New("exr: subsampled channels are not supported")
+				}
+				channels = append(channels, exrChannel{name: chName, pixelType: pixelType})
+				q += 16
+			}
+		case name == "compression" && size == 1:
+			compression = int(value[0])
+		case name == "dataWindow" && size == 16:
+			xMin = int32(binary.LittleEndian.Uint32(value))
+			yMin = int32(binary.LittleEndian.Uint32(value[4:]))
+			xMax = int32(binary.LittleEndian.Uint32(value[8:]))
+			yMax = int32(binary.LittleEndian.Uint32(value[12:]))
+			haveWindow = true
+		}
+	}
+
+	if len(channels) == 0 || !haveWindow {
+		return nil, errors.New("exr: missing channels or data window")
+	}
+	linesPerChunk := 1
+	switch compression {
+	case exrNoCompression, exrRLE, exrZIPS:
+	case exrZIP:
+		linesPerChunk = 16
+	default:
+		return nil, fmt.Errorf("exr: compression %d is not supported, re-export with ZIP or no compression", compression)
+	}
+
+	w, h := int(xMax-xMin)+1, int(yMax-yMin)+1
+	if w <= 0 || h <= 0 || w > maxHDRPixels/h {
+		return nil, fmt.Errorf("exr: invalid size %dx%d", w, h)
+	}
+
+	// R, G, B channels by name; a luminance-only file uses Y for all three
+	rgb := [3]int{-1, -1, -1}
+	for i, c := range channels {
+		switch c.name {
+		case "R":
+			rgb[0] = i
+		case "G":
+			rgb[1] = i
+		case "B":
+			rgb[2] = i
+		case "Y":
+			if rgb[0] < 0 {
+				rgb = [3]int{i, i, i}
+			}
+		}
+	}
+	if rgb[0] < 0 || rgb[1] < 0 || rgb[2] < 0 {
+		return nil, errors.New("exr: no RGB or Y channels")
+	}
+
+	lineSize := 0
+	offsets := make([]int, len(channels)) // of each channel within a scanline
+	for i, c := range channels {
+		offsets[i] = lineSize * w
+		lineSize += c.size()
+	}
+	lineSize *= w
+
+	chunks := (h + linesPerChunk - 1) / linesPerChunk
+	if p+chunks*8 > len(data) {
+		return nil, errors.New("exr: truncated offset table")
+	}
+	img := newHDRImage(w, h)
+	for i := 0; i < chunks; i++ {
+		off := int(binary.LittleEndian.Uint64(data[p+i*8:]))
+		if off < 0 || off+8 > len(data) {
+			return nil, errors.New("exr: bad chunk offset")
+		}
+		y0 := int(int32(binary.LittleEndian.Uint32(data[off:]))) - int(yMin)
+		size := int(binary.LittleEndian.Uint32(data[off+4:]))
+		if off+8+size > len(data) || y0 < 0 || y0 >= h {
+			return nil, errors.New("exr: bad chunk")
+		}
+		lines := min(linesPerChunk, h-y0)
+		block, err := exrDecompress(data[off+8:off+8+size], compression, lines*lineSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for ly := 0; ly < lines; ly++ {
+			line := block[ly*lineSize : (ly+1)*lineSize]
+			row := img.Pix[(y0+ly)*w*3:]
+			for ch, ci := range rgb {
+				c := channels[ci]
+				base := offsets[ci]
+				for x := 0; x < w; x++ {
+					row[x*3+ch] = exrValue(line[base+x*c.size():], c.pixelType)
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+func exrString(b []byte, p *int) (string, bool) {
+	end := bytes.IndexByte(b[*p:], 0)
+	if end < 0 {
+		return "", false
+	}
+	s := string(b[*p : *p+end])
+	*p += end + 1
+	return s, true
+}
+
+func exrValue(b []byte, pixelType int32) float32 {
+	switch pixelType {
+	case exrHalf:
+		return halfToFloat(binary.LittleEndian.Uint16(b))
+	case exrFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	default:
+		return float32(binary.LittleEndian.Uint32(b))
+	}
+}
+
+// exrDecompress returns the raw scanline bytes of a chunk
+func exrDecompress(src []byte, compression, rawSize int) ([]byte, error) {
+	// Chunks that would not shrink are stored uncompressed
+	if compression == exrNoCompression || len(src) == rawSize {
+		if len(src) != rawSize {
+			return nil, errors.New("exr: chunk size mismatch")
+		}
+		return src, nil
+	}
+
+	var tmp []byte
+	switch compression {
+	case exrRLE:
+		tmp = make([]byte, 0, rawSize)
+		for i := 0; i < len(src); {
+			n := int(int8(src[i]))
+			i++
+			if n < 0 {
+				if i-n > len(src) {
+					return nil, errors.New("exr: bad RLE run")
+				}
+				tmp = append(tmp, src[i:i-n]...)
+				i -= n
+			} else {
+				if i >= len(src) {
+					return nil, errors.New("exr: bad RLE run")
+				}
+				for j := 0; j <= n; j++ {
+					tmp = append(tmp, src[i])
+				}
+				i++
+			}
+		}
+	case exrZIPS, exrZIP:
+		zr, err := zlib.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, fmt.Errorf("exr: %w", err)
+		}
+		tmp, err = io.ReadAll(io.LimitReader(zr, int64(rawSize)+1))
+		if err != nil {
+			return nil, fmt.Errorf("exr: %w", err)
+		}
+	}
+	if len(tmp) != rawSize {
+		return nil, errors.New("exr: chunk size mismatch")
+	}
+
+	// Undo the byte predictor, then interleave the two halves back together
+	for i := 1; i < len(tmp); i++ {
+		tmp[i] = tmp[i-1] + tmp[i] - 128
+	}
+	out := make([]byte, rawSize)
+	half := (rawSize + 1) / 2
+	for i := 0; i < rawSize; i++ {
+		if i%2 == 0 {
+			out[i] = tmp[i/2]
+		} else {
+			out[i] = tmp[half+i/2]
+		}
+	}
+	return out, nil
+}
+
+// halfToFloat converts an IEEE 754 half precision value
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+
+	switch {
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal: renormalise into a float32 normal
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// ProjectionDualFisheye is the raw output of two-lens consumer cameras (Ricoh Theta,
+// Insta360): two fisheye circles side by side, the left one facing forward.
+const ProjectionDualFisheye = "dual_fisheye"
+
+// DefaultFisheyeFOV is the lens field of view assumed when none is given, in degrees
+const DefaultFisheyeFOV = 190
+
+// Lens locates one fisheye circle inside its half of the image. X and Y are
+// fractions of the half's width and height, Radius a fraction of its height.
+// Zero values mean a centred circle touching the top and bottom edges.
+type Lens struct {
+	X      float64 `json:"x,omitempty"`
+	Y      float64 `json:"y,omitempty"`
+	Radius float64 `json:"radius,omitempty"`
+}
+
+// Fisheye describes the lenses of a dual-fisheye capture
+type Fisheye struct {
+	FOV    float64 `json:"fov,omitempty"` // per lens, DefaultFisheyeFOV when zero
+	Lenses [2]Lens `json:"lenses"`        // front (left half) and back (right half)
+}
+
+// lensCircle returns the circle centre and radius of lens i in pixels
+func (f Fisheye) lensCircle(i int, b image.Rectangle) (cx, cy, r float64) {
+	hw, h := float64(b.Dx())/2, float64(b.Dy())
+	l := f.Lenses[i]
+	if l.X == 0 {
+		l.X = 0.5
+	}
+	if l.Y == 0 {
+		l.Y = 0.5
+	}
+	if l.Radius == 0 {
+		l.Radius = 0.5
+	}
+	return float64(b.Min.X) + (float64(i)+l.X)*hw, float64(b.Min.Y) + l.Y*h, l.Radius * h
+}
+
+// DetectDualFisheye reports whether src looks like two fisheye circles side by side.
+// A wrong guess remaps the panorama irreversibly, so it has to be convincing: a 2:1
+// image where, for both halves, the ring just outside the lens circle is black and
+// the ring just inside it is clearly brighter all the way round, and everything
+// further outside the circle is black too. Dark-cornered equirects fail on the seam
+// corners and on the circular edge.
+func DetectDualFisheye(src image.Image) bool {
+	b := src.Bounds()
+	if b.Dx() < 64 || math.Abs(float64(b.Dx())/float64(b.Dy())-2) > 0.02 {
+		return false
+	}
+
+	var f Fisheye
+	hw := b.Dx() / 2
+	for i := 0; i < 2; i++ {
+		half := image.Rect(b.Min.X+i*hw, b.Min.Y, b.Min.X+(i+1)*hw, b.Max.Y)
+		cx, cy, r := f.lensCircle(i, b)
+
+		// The edge of the circle, wherever the ring outside it is still within this half
+		var edge, sharp int
+		for a := 0; a < 360; a += 3 {
+			dx, dy := math.Cos(float64(a)*math.Pi/180), math.Sin(float64(a)*math.Pi/180)
+			out := image.Pt(int(cx+dx*r*1.06), int(cy+dy*r*1.06))
+			if !out.In(half) {
+				continue
+			}
+			edge++
+			outside := luminance(src.At(out.X, out.Y))
+			inside := luminance(src.At(int(cx+dx*r*0.92), int(cy+dy*r*0.92)))
+			if outside <= 20 && inside >= outside+24 {
+				sharp++
+			}
+		}
+		if edge < 32 || float64(sharp) < 0.9*float64(edge) {
+			return false
+		}
+
+		// Everything well outside the circle, on a grid over the half
+		var around, dark int
+		for gy := 0; gy < 24; gy++ {
+			for gx := 0; gx < 24; gx++ {
+				x := float64(half.Min.X) + (float64(gx)+0.5)*float64(half.Dx())/24
+				y := float64(half.Min.Y) + (float64(gy)+0.5)*float64(half.Dy())/24
+				if math.Hypot(x-cx, y-cy) < r*1.1 {
+					continue
+				}
+				around++
+				if luminance(src.At(int(x), int(y))) <= 20 {
+					dark++
+				}
+			}
+		}
+		if around < 16 || float64(dark) < 0.95*float64(around) {
+			return false
+		}
+	}
+	return true
+}
+
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
+
+// DualFisheyeToEquirect stitches a dual-fisheye capture into a full equirectangular panorama
+// of the same width, assuming equidistant lenses. Where the lenses overlap (FOV beyond 180°)
+// the two images are blended linearly across the seam.
+func DualFisheyeToEquirect(src image.Image, f Fisheye) *image.NRGBA {
+	fov := f.FOV
+	if fov <= 0 {
+		fov = DefaultFisheyeFOV
+	}
+	halfFov := fov / 2
+	overlap := math.Max(0, halfFov-90)
+
+	in := imaging.Clone(src)
+	b := in.Bounds()
+	var cx, cy, r [2]float64
+	for i := range cx {
+		cx[i], cy[i], r[i] = f.lensCircle(i, b)
+	}
+
+	w := b.Dx()
+	h := w / 2
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		pitch := 90 - (float64(y)+0.5)/float64(h)*180
+		for x := 0; x < w; x++ {
+			yaw := (float64(x)+0.5)/float64(w)*360 - 180
+			dx, dy, dz := direction(yaw, pitch)
+
+			// Angle from the front lens axis; the back lens sees the mirrored direction
+			theta := math.Acos(math.Max(-1, math.Min(1, dz))) * 180 / math.Pi
+			front := 1.0
+			if overlap > 0 {
+				front = math.Max(0, math.Min(1, (90+overlap-theta)/(2*overlap)))
+			} else if theta > 90 {
+				front = 0
+			}
+
+			var c [4]float64
+			for i, weight := range [2]float64{front, 1 - front} {
+				if weight == 0 {
+					continue
+				}
+				t, lx := theta, dx
+				if i == 1 {
+					t, lx = 180-theta, -dx
+				}
+				rr := t / halfFov * r[i]
+				n := math.Hypot(lx, dy)
+				px, py := cx[i], cy[i]
+				if n > 0 {
+					px += rr * lx / n
+					py -= rr * dy / n
+				}
+				s := in.NRGBAAt(clampInt(int(px), b.Min.X, b.Max.X-1), clampInt(int(py), b.Min.Y, b.Max.Y-1))
+				c[0] += weight * float64(s.R)
+				c[1] += weight * float64(s.G)
+				c[2] += weight * float64(s.B)
+				c[3] += weight * float64(s.A)
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(c[0] + 0.5), uint8(c[1] + 0.5), uint8(c[2] + 0.5), uint8(c[3] + 0.5)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HDRImage is a linear, floating point RGB image as decoded from .hdr and .exr files
+type HDRImage struct {
+	Width, Height int
+	Pix           []float32 // RGB triples, row by row
+}
+
+func newHDRImage(w, h int) *HDRImage {
+	return &HDRImage{Width: w, Height: h, Pix: make([]float32, w*h*3)}
+}
+
+// IsHDRFile reports whether name is a Radiance HDR or OpenEXR file by extension
+func IsHDRFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".hdr", ".exr":
+		return true
+	}
+	return false
+}
+
+// DecodeHDRFile reads a Radiance .hdr or OpenEXR .exr file
+func DecodeHDRFile(path string) (*HDRImage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".exr" {
+		return DecodeEXR(f)
+	}
+	return DecodeRadiance(f)
+}
+
+// DecodeRadiance reads a Radiance RGBE (.hdr) image, flat or run-length encoded
+func DecodeRadiance(r io.Reader) (*HDRImage, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(magic, "#?") {
+		return nil, errors.New("not a Radiance HDR file")
+	}
+
+	// Header lines until the blank line, then the resolution string
+	exposure := 1.0
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("hdr header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "FORMAT="); ok && v != "32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("unsupported hdr format %q", v)
+		}
+		if v, ok := strings.CutPrefix(line, "EXPOSURE="); ok {
+			if e, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && e > 0 {
+				exposure *= e
+			}
+		}
+	}
+
+	res, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("hdr resolution: %w", err)
+	}
+	var ySign, xSign string
+	var h, w int
+	if _, err := fmt.Sscanf(res, "%2s %d %2s %d", &ySign, &h, &xSign, &w); err != nil || xSign != "+X" || (ySign != "-Y" && ySign != "+Y") {
+		return nil, fmt.Errorf("unsupported hdr orientation %q", strings.TrimSpace(res))
+	}
+	if w <= 0 || h <= 0 || w > maxHDRPixels/h {
+		return nil, fmt.Errorf("invalid hdr size %dx%d", w, h)
+	}
+
+	img := newHDRImage(w, h)
+	scan := make([]byte, w*4)
+	for y := 0; y < h; y++ {
+		if err := readRGBEScanline(br, scan); err != nil {
+			return nil, fmt.Errorf("hdr scanline %d: %w", y, err)
+		}
+		row := y
+		if ySign == "+Y" {
+			row = h - 1 - y
+		}
+		for x := 0; x < w; x++ {
+			e := scan[x*4+3]
+			if e == 0 {
+				continue
+			}
+			f := math.Ldexp(1, int(e)-(128+8)) / exposure
+			i := (row*w + x) * 3
+			img.Pix[i] = float32((float64(scan[x*4]) + 0.5) * f)
+			img.Pix[i+1] = float32((float64(scan[x*4+1]) + 0.5) * f)
+			img.Pix[i+2] = float32((float64(scan[x*4+2]) + 0.5) * f)
+		}
+	}
+	return img, nil
+}
+
+// maxHDRPixels keeps a corrupt header from allocating gigabytes (16k × 8k)
+const maxHDRPixels = 16384 * 8192
+
+// readRGBEScanline fills scan with one row of RGBE pixels
+func readRGBEScanline(br *bufio.Reader, scan []byte) error {
+	w := len(scan) / 4
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return err
+	}
+
+	// New style RLE: 2, 2, width, then each channel run-length encoded separately
+	if w >= 8 && w < 0x8000 && head[0] == 2 && head[1] == 2 && head[2]&0x80 == 0 {
+		if int(head[2])<<8|int(head[3]) != w {
+			return errors.New("scanline width mismatch")
+		}
+		for ch := 0; ch < 4; ch++ {
+			for x := 0; x < w; {
+				count, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if count > 128 {
+					n := int(count - 128)
+					v, err := br.ReadByte()
+					if err != nil {
+						return err
+					}
+					if x+n > w {
+						return errors.New("run overflows scanline")
+					}
+					for ; n > 0; n-- {
+						scan[x*4+ch] = v
+						x++
+					}
+				} else {
+					n := int(count)
+					if n == 0 || x+n > w {
+						return errors.New("bad literal run")
+					}
+					for ; n > 0; n-- {
+						v, err := br.ReadByte()
+						if err != nil {
+							return err
+						}
+						scan[x*4+ch] = v
+						x++
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	// Flat pixels, possibly with old style (1, 1, 1, n) repeats of the previous pixel
+	copy(scan, head)
+	shift := 0
+	for x := 1; x < w; {
+		px := scan[x*4 : x*4+4]
+		if _, err := io.ReadFull(br, px); err != nil {
+			return err
+		}
+		if px[0] == 1 && px[1] == 1 && px[2] == 1 {
+			n := int(px[3]) << shift
+			if x+n > w {
+				return errors.New("run overflows scanline")
+			}
+			for ; n > 0; n-- {
+				copy(scan[x*4:x*4+4], scan[(x-1)*4:x*4])
+				x++
+			}
+			shift += 8
+			continue
+		}
+		shift = 0
+		x++
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// Manifest is stored as JSON on models.Scene and tells clients which files make up a scene.
+// All paths are relative to the scene directory.
+type Manifest struct {
+	Stereo     string            `json:"stereo"`
+	Faces      []string          `json:"faces"`
+	RightFaces []string          `json:"right_faces,omitempty"`
+	Thumbnail  string            `json:"thumbnail,omitempty"`
+	Thumbnails map[string]string `json:"thumbnails,omitempty"` // square, card, social
+	Preview    string            `json:"preview,omitempty"`
+	Blurhash   string            `json:"blurhash,omitempty"`
+	// Encoded alternatives (JPEG, WebP, AVIF) keyed by the JPEG path listed above,
+	// so clients can pick the best format they support
+	Variants map[string][]Variant `json:"variants,omitempty"`
+}
+
+// Manifest builds the scene manifest with paths relative to sceneDir
+func (r *Result) Manifest(sceneDir string) Manifest {
+	return Manifest{
+		Stereo:     r.Stereo,
+		Faces:      relPaths(sceneDir, r.Faces),
+		RightFaces: relPaths(sceneDir, r.RightFaces),
+		Thumbnail:  relPath(sceneDir, r.Thumbnail),
+		Thumbnails: relPathMap(sceneDir, r.Thumbnails),
+		Preview:    relPath(sceneDir, r.Preview),
+		Blurhash:   r.Blurhash,
+		Variants:   relVariants(sceneDir, r.Variants),
+	}
+}
+
+func relPathMap(base string, paths map[string]string) map[string]string {
+	if len(paths) == 0 {
+		return nil
+	}
+	out := map[string]string{}
+	for k, p := range paths {
+		out[k] = relPath(base, p)
+	}
+	return out
+}
+
+func relVariants(base string, variants map[string][]Variant) map[string][]Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	out := map[string][]Variant{}
+	for path, vs := range variants {
+		rel := make([]Variant, len(vs))
+		for i, v := range vs {
+			rel[i] = v
+			rel[i].Path = relPath(base, v.Path)
+		}
+		out[relPath(base, path)] = rel
+	}
+	return out
+}
+
+// JSON returns the manifest encoded for models.Scene.Manifest
+func (m Manifest) JSON() string {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func relPaths(base string, paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		out = append(out, relPath(base, p))
+	}
+	return out
+}
+
+func relPath(base, p string) string {
+	if p == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(base, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import "math"
+
+// Orientation rotates the sphere before cube extraction, in degrees.
+// Each output direction is looked up in the source at the direction rotated by
+// yaw (around the vertical axis), then pitch and roll.
+type Orientation struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+	Roll  float64 `json:"roll"`
+}
+
+// IsZero reports whether o leaves the sphere unchanged
+func (o Orientation) IsZero() bool {
+	return o.Yaw == 0 && o.Pitch == 0 && o.Roll == 0
+}
+
+// PoseCorrection returns the rotation that levels a panorama shot with the given GPano pose
+func PoseCorrection(gp *GPano) Orientation {
+	var o Orientation
+	if gp == nil {
+		return o
+	}
+	if gp.PosePitchDegrees != nil {
+		o.Pitch = -*gp.PosePitchDegrees
+	}
+	if gp.PoseRollDegrees != nil {
+		o.Roll = -*gp.PoseRollDegrees
+	}
+	return o
+}
+
+type mat3 [3][3]float64
+
+func (m mat3) mul(n mat3) mat3 {
+	var r mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m mat3) transpose() mat3 {
+	var r mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m[j][i]
+		}
+	}
+	return r
+}
+
+func (m mat3) apply(x, y, z float64) (float64, float64, float64) {
+	return m[0][0]*x + m[0][1]*y + m[0][2]*z,
+		m[1][0]*x + m[1][1]*y + m[1][2]*z,
+		m[2][0]*x + m[2][1]*y + m[2][2]*z
+}
+
+// matrix maps an output direction (x right, y up, z forward) to the source direction
+func (o Orientation) matrix() mat3 {
+	y, p, r := o.Yaw*math.Pi/180, o.Pitch*math.Pi/180, o.Roll*math.Pi/180
+	ry := mat3{{math.Cos(y), 0, math.Sin(y)}, {0, 1, 0}, {-math.Sin(y), 0, math.Cos(y)}}
+	rx := mat3{{1, 0, 0}, {0, math.Cos(p), -math.Sin(p)}, {0, math.Sin(p), math.Cos(p)}}
+	rz := mat3{{math.Cos(r), -math.Sin(r), 0}, {math.Sin(r), math.Cos(r), 0}, {0, 0, 1}}
+	return ry.mul(rx).mul(rz)
+}
+
+// Reorient moves a yaw/pitch position (degrees) from a scene sliced with orientation
+// from to the same spot in the scene sliced with orientation to, so hotspots stay put.
+func Reorient(yaw, pitch float64, from, to Orientation) (float64, float64) {
+	x, y, z := direction(yaw, pitch)
+	x, y, z = from.matrix().apply(x, y, z)
+	x, y, z = to.matrix().transpose().apply(x, y, z)
+	return angles(x, y, z)
+}
+
+func direction(yaw, pitch float64) (float64, float64, float64) {
+	y, p := yaw*math.Pi/180, pitch*math.Pi/180
+	return math.Sin(y) * math.Cos(p), math.Sin(p), math.Cos(y) * math.Cos(p)
+}
+
+func angles(x, y, z float64) (float64, float64) {
+	yaw := math.Atan2(x, z) * 180 / math.Pi
+	pitch := math.Atan2(y, math.Sqrt(x*x+z*z)) * 180 / math.Pi
+	return yaw, pitch
+}
@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Preview sizes: a small equirect for instant placeholders and the blurhash grid
+const (
+	PreviewWidth      = 512
+	blurhashXComps    = 4
+	blurhashYComps    = 3
+	blurhashSampleDim = 64
+)
+
+// RenderEquirect resamples an equirect to w×h after rotating the sphere by rot,
+// so the preview matches what the cube faces show.
+func RenderEquirect(src image.Image, w, h int, rot Orientation) *image.NRGBA {
+	small := imaging.Resize(src, w*2, h*2, imaging.Box)
+	if rot.IsZero() {
+		return imaging.Resize(small, w, h, imaging.Linear)
+	}
+
+	m := rot.matrix()
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		pitch := 90 - (float64(y)+0.5)/float64(h)*180
+		for x := 0; x < w; x++ {
+			yaw := (float64(x)+0.5)/float64(w)*360 - 180
+			syaw, spitch := angles(m.apply(direction(yaw, pitch)))
+			sx := int((syaw + 180) / 360 * float64(sw))
+			sy := int((90 - spitch) / 180 * float64(sh))
+			dst.Set(x, y, small.At(clampInt(sx, 0, sw-1), clampInt(sy, 0, sh-1)))
+		}
+	}
+	return dst
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Blurhash encodes img as a blurhash string (https://blurha.sh) with 4×3 components
+func Blurhash(img image.Image) string {
+	small := imaging.Resize(img, blurhashSampleDim, blurhashSampleDim/2, imaging.Box)
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+
+	factors := make([][3]float64, 0, blurhashXComps*blurhashYComps)
+	for j := 0; j < blurhashYComps; j++ {
+		for i := 0; i < blurhashXComps; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var r, g, b float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := small.PixOffset(x, y)
+					r += basis * srgbToLinear(small.Pix[p])
+					g += basis * srgbToLinear(small.Pix[p+1])
+					b += basis * srgbToLinear(small.Pix[p+2])
+				}
+			}
+			scale := norm / float64(w*h)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base83((blurhashXComps-1)+(blurhashYComps-1)*9, 1))
+
+	maxValue := 1.0
+	ac := factors[1:]
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := clampInt(int(math.Floor(actualMax*166-0.5)), 0, 82)
+		maxValue = float64(quantisedMax+1) / 166
+		sb.WriteString(base83(quantisedMax, 1))
+	} else {
+		sb.WriteString(base83(0, 1))
+	}
+
+	dc := factors[0]
+	sb.WriteString(base83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+
+	for _, f := range ac {
+		q := func(v float64) int {
+			return clampInt(int(math.Floor(signPow(v/maxValue, 0.5)*9+9.5)), 0, 18)
+		}
+		sb.WriteString(base83(q(f[0])*19*19+q(f[1])*19+q(f[2]), 2))
+	}
+	return sb.String()
+}
+
+func base83(value, length int) string {
+	out := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		out[i-1] = base83Chars[digit]
+	}
+	return string(out)
+}
+
+func srgbToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
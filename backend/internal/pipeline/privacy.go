@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EXIF tags that identify a person or a specific device
+var identifyingTags = map[uint16]bool{
+	0x013B: true, // Artist
+	0x013C: true, // HostComputer
+	0x9286: true, // UserComment
+	0x927C: true, // MakerNote, often carries serial numbers
+	0xA420: true, // ImageUniqueID
+	0xA430: true, // CameraOwnerName
+	0xA431: true, // BodySerialNumber
+	0xA435: true, // LensSerialNumber
+}
+
+// StripMetadata rewrites a JPEG without GPS and identifying EXIF fields.
+// XMP is reduced to its GPano properties, IPTC and comments are dropped.
+// With keepLocation the GPS block is left in place. Non-JPEG files are left untouched.
+func StripMetadata(path string, keepLocation bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+	r := bytes.NewReader(data[2:])
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+			return fmt.Errorf("strip metadata: %w", err)
+		}
+		if hdr[0] != 0xFF {
+			return fmt.Errorf("strip metadata: invalid JPEG marker")
+		}
+		marker := hdr[1]
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan: copy the marker and everything after it verbatim
+			out.Write(hdr[:2])
+			io.Copy(&out, r)
+			break
+		}
+		if _, err := io.ReadFull(r, hdr[2:]); err != nil {
+			return fmt.Errorf("strip metadata: %w", err)
+		}
+		length := int(binary.BigEndian.Uint16(hdr[2:]))
+		if length < 2 {
+			return fmt.Errorf("strip metadata: invalid segment length")
+		}
+		seg := make([]byte, length-2)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return fmt.Errorf("strip metadata: %w", err)
+		}
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(seg, exifHeader):
+			scrubExif(seg[len(exifHeader):], keepLocation)
+		case marker == 0xE1 && bytes.HasPrefix(seg, xmpHeader):
+			packet := gpanoOnlyXMP(string(seg[len(xmpHeader):]))
+			if packet == "" {
+				continue
+			}
+			seg = append(append([]byte{}, xmpHeader...), packet...)
+			binary.BigEndian.PutUint16(hdr[2:], uint16(len(seg)+2))
+		case marker == 0xE1, marker == 0xED, marker == 0xFE:
+			// Extended XMP, Photoshop/IPTC and comments
+			continue
+		}
+		out.Write(hdr[:])
+		out.Write(seg)
+	}
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// scrubExif blanks identifying values in place so offsets stay valid
+func scrubExif(b []byte, keepLocation bool) {
+	t, err := newTIFF(b)
+	if err != nil {
+		return
+	}
+	ifd0, _ := t.readIFD(t.firstIFD())
+	t.blank(ifd0, identifyingTags)
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		exif, _ := t.readIFD(int(t.long(e)))
+		t.blank(exif, identifyingTags)
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok && !keepLocation {
+		offset := int(t.long(e))
+		gps, _ := t.readIFD(offset)
+		t.blank(gps, nil)
+		// An empty directory is still a valid GPS IFD
+		if offset > 0 && offset+2 <= len(t.b) {
+			t.bo.PutUint16(t.b[offset:], 0)
+		}
+	}
+}
+
+// blank zeroes the values of the given tags, or of every entry when tags is nil
+func (t *tiff) blank(entries map[uint16]tiffEntry, tags map[uint16]bool) {
+	for tag, e := range entries {
+		if tags != nil && !tags[tag] {
+			continue
+		}
+		v := t.b[e.valueOffset : e.valueOffset+t.size(e)]
+		for i := range v {
+			v[i] = 0
+		}
+	}
+}
+
+// gpanoOnlyXMP rebuilds an XMP packet that only carries the GPano properties
+func gpanoOnlyXMP(packet string) string {
+	props := xmpProperties(packet, "GPano")
+	if len(props) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`)
+	sb.WriteString(`<rdf:Description rdf:about="" xmlns:GPano="http://ns.google.com/photos/1.0/panorama/"`)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, ` GPano:%s="%s"`, k, props[k])
+	}
+	sb.WriteString(`/></rdf:RDF></x:xmpmeta>`)
+	return sb.String()
+}
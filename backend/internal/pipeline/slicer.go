@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"image"
+	"image/color"
 	"math"
 	"os"
 	"path/filepath"
@@ -9,8 +10,79 @@ import (
 	"github.com/disintegration/imaging"
 )
 
+// FaceNames lists the cube faces in the order ExtractFace expects them
+var FaceNames = []string{"posx", "negx", "posy", "negy", "posz", "negz"}
+
+// Options controls how SlicePanoWithOptions interprets and renders a panorama.
+// They are stored with each scene so it can be re-sliced later with the same settings.
+type Options struct {
+	Stereo     string  `json:"stereo"`     // StereoAuto (default), StereoMono, StereoTopBottom or StereoSideBySide
+	Projection string  `json:"projection"` // ProjectionEquirect (default), ProjectionCylindrical or ProjectionDualFisheye
+	HFov       float64 `json:"h_fov"`      // degrees covered horizontally, 0 = from GPano tags or 360
+	VFov       float64 `json:"v_fov"`      // degrees covered vertically, 0 = from GPano tags or image aspect
+	Fill       string  `json:"fill"`       // #rrggbb for the part of the sphere partial input does not cover, black by default
+
+	// Fisheye holds the lens parameters for ProjectionDualFisheye. Without an explicit
+	// projection, dual-fisheye input is detected from its black corners.
+	Fisheye *Fisheye `json:"fisheye,omitempty"`
+
+	// ToneMap and Exposure (in stops) turn .hdr/.exr input into the LDR faces and thumbnails
+	ToneMap  string  `json:"tone_map,omitempty"`
+	Exposure float64 `json:"exposure,omitempty"`
+
+	// Rotation is applied to the sphere before cube extraction. With AutoLevel and
+	// no explicit rotation the GPano pose pitch/roll is used to level the horizon.
+	Rotation  Orientation `json:"rotation"`
+	AutoLevel bool        `json:"auto_level"`
+
+	// StripMetadata removes GPS and identifying EXIF/XMP fields from the input once it has been read.
+	// KeepLocation leaves the GPS block in place for intentional map features.
+	StripMetadata bool `json:"strip_metadata"`
+	KeepLocation  bool `json:"keep_location"`
+
+	// Encoding profiles for cube faces and thumbnails, DefaultProfiles when empty.
+	// A JPEG variant is always written as the fallback.
+	FaceProfiles      []Profile `json:"face_profiles,omitempty"`
+	ThumbnailProfiles []Profile `json:"thumbnail_profiles,omitempty"`
+
+	// ThumbnailView is where thumbnails look, in viewer coordinates. Nil uses the initial view.
+	// InitialView overrides the GPano initial view when set.
+	ThumbnailView *YawPitch `json:"thumbnail_view,omitempty"`
+	InitialView   *YawPitch `json:"initial_view,omitempty"`
+
+	// Branding and blur regions are resolved from the database on every run, so they are not stored
+	Branding    *Branding    `json:"-"`
+	BlurRegions []BlurRegion `json:"-"`
+}
+
+// Result describes everything SlicePanoWithOptions wrote to disk
+type Result struct {
+	Stereo     string
+	Projection string            // projection the input was read as
+	ToneMap    string            // operator used for HDR input, empty otherwise
+	Faces      []string          // left eye when stereo
+	RightFaces []string          // only set for stereo input
+	Thumbnail  string            // the "square" thumbnail
+	Thumbnails map[string]string // by ThumbnailSize name
+	Preview    string            // small equirect placeholder, left eye for stereo
+	Blurhash   string
+	Coverage   Coverage
+	Metadata   *Metadata
+	Rotation   Orientation          // what was actually applied, including AutoLevel
+	Variants   map[string][]Variant // every encoded variant, keyed by the JPEG path
+	// Initial view relative to the image centre, taken from GPano InitialView tags
+	InitialYaw   float64
+	InitialPitch float64
+}
+
 // ExtractFace extracts one face of a cubemap from an equirectangular panorama
 func ExtractFace(input image.Image, face int, faceSize int) image.Image {
+	return ExtractFaceRotated(input, face, faceSize, Orientation{})
+}
+
+// ExtractFaceRotated extracts one face of a cubemap after rotating the sphere by rot
+func ExtractFaceRotated(input image.Image, face int, faceSize int, rot Orientation) image.Image {
+	m := rot.matrix()
 	img := image.NewRGBA(image.Rect(0, 0, faceSize, faceSize))
 
 	for y := 0; y < faceSize; y++ {
@@ -35,6 +107,10 @@ func ExtractFace(input image.Image, face int, faceSize int) image.Image {
 				vx, vy, vz = -u, -v, -1.0
 			}
 
+			if !rot.IsZero() {
+				vx, vy, vz = m.apply(vx, vy, vz)
+			}
+
 			// Convert unit vector to spherical coordinates
 			phi := math.Atan2(vx, vz)
 			theta := math.Atan2(vy, math.Sqrt(vx*vx+vz*vz))
@@ -52,38 +128,174 @@ func ExtractFace(input image.Image, face int, faceSize int) image.Image {
 }
 
 func SlicePano(inputPath string, outputDir string) ([]string, error) {
-	src, err := imaging.Open(inputPath)
+	res, err := SlicePanoWithOptions(inputPath, outputDir, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Faces, nil
+}
+
+// SlicePanoWithOptions slices inputPath into outputDir and writes the thumbnail next to it.
+// Stereo input gets a second cubemap for the right eye in outputDir + "_right".
+func SlicePanoWithOptions(inputPath string, outputDir string, opts Options) (*Result, error) {
+	if IsHDRFile(inputPath) && opts.ToneMap == "" {
+		opts.ToneMap = ToneMapReinhard
+	}
+	src, err := OpenPanorama(inputPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := ReadMetadata(inputPath)
+	if err != nil {
+		// Broken headers should not stop an otherwise decodable image
+		md = &Metadata{}
+	}
+	gp := md.GPano
+	if gp != nil {
+		if err := gp.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	projection := opts.Projection
+	if projection == "" && gp != nil && gp.ProjectionType != "" {
+		projection = gp.ProjectionType
+	}
+
+	// Raw dual-fisheye captures are stitched into a full equirect before anything else
+	if projection == ProjectionDualFisheye || (projection == "" && DetectDualFisheye(src)) {
+		var lenses Fisheye
+		if opts.Fisheye != nil {
+			lenses = *opts.Fisheye
+		}
+		src = DualFisheyeToEquirect(src, lenses)
+		projection = ProjectionDualFisheye
+	}
+	if projection == "" {
+		projection = ProjectionEquirect
+	}
+	opts.Projection = projection
+
+	mode := ResolveStereo(src.Bounds(), opts, gp)
+	left, right, err := SplitStereo(src, mode)
 	if err != nil {
 		return nil, err
 	}
 
+	// Partial panoramas get padded to a full sphere before slicing
+	coverage := FullCoverage
+	if projection != ProjectionDualFisheye {
+		coverage = ResolveCoverage(left.Bounds(), opts, gp)
+	}
+	if !coverage.FullSphere() || projection == ProjectionCylindrical {
+		var fill color.Color = color.Black
+		if opts.Fill != "" {
+			if fill, err = ParseHexColor(opts.Fill); err != nil {
+				return nil, err
+			}
+		}
+		left = PadToSphere(left, coverage, projection, fill)
+		if right != nil {
+			right = PadToSphere(right, coverage, projection, fill)
+		}
+	}
+
+	rotation := opts.Rotation
+	if rotation.IsZero() && opts.AutoLevel {
+		rotation = PoseCorrection(gp)
+	}
+
+	// Redact before extraction so faces, thumbnails and every later derivative are covered
+	if len(opts.BlurRegions) > 0 {
+		left = ApplyBlur(left, opts.BlurRegions, rotation)
+		if right != nil {
+			right = ApplyBlur(right, opts.BlurRegions, rotation)
+		}
+	}
+
+	res := &Result{Stereo: mode, Projection: projection, ToneMap: opts.ToneMap, Coverage: coverage, Metadata: md, Rotation: rotation, Variants: map[string][]Variant{}}
+	if opts.InitialView != nil {
+		res.InitialYaw, res.InitialPitch = opts.InitialView.Yaw, opts.InitialView.Pitch
+	} else if gp != nil {
+		res.InitialYaw, res.InitialPitch = gp.InitialView()
+	}
+	res.Faces, err = sliceCube(left, outputDir, rotation, opts, res.Variants)
+	if err != nil {
+		return nil, err
+	}
+	if right != nil {
+		res.RightFaces, err = sliceCube(right, outputDir+"_right", rotation, opts, res.Variants)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Low-res placeholder the viewer and dashboard can show while faces load
+	preview := RenderEquirect(left, PreviewWidth, PreviewWidth/2, rotation)
+	res.Blurhash = Blurhash(preview)
+	preview = imaging.Clone(ApplyWatermark(preview, opts.Branding))
+	previewVariants, err := SaveVariants(preview, filepath.Join(filepath.Dir(outputDir), "preview"), opts.ThumbnailProfiles)
+	if err != nil {
+		return nil, err
+	}
+	res.Preview = previewVariants[0].Path
+	res.Variants[res.Preview] = previewVariants
+
+	// Thumbnails look at the chosen view, falling back to the initial view, always the left eye for stereo
+	view := YawPitch{Yaw: res.InitialYaw, Pitch: res.InitialPitch}
+	if opts.ThumbnailView != nil {
+		view = *opts.ThumbnailView
+	}
+	res.Thumbnails = map[string]string{}
+	for _, size := range ThumbnailSizes {
+		thumb := RenderView(left, view, size.HFov, size.Width, size.Height, rotation)
+		if opts.Branding != nil && opts.Branding.WatermarkThumbnail {
+			thumb = imaging.Clone(ApplyWatermark(thumb, opts.Branding))
+		}
+		variants, err := SaveVariants(thumb, filepath.Join(filepath.Dir(outputDir), size.File), opts.ThumbnailProfiles)
+		if err != nil {
+			return nil, err
+		}
+		res.Thumbnails[size.Name] = variants[0].Path
+		res.Variants[variants[0].Path] = variants
+	}
+	res.Thumbnail = res.Thumbnails["square"]
+
+	if opts.StripMetadata {
+		if err := StripMetadata(inputPath, opts.KeepLocation); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func sliceCube(src image.Image, outputDir string, rot Orientation, opts Options, variants map[string][]Variant) ([]string, error) {
 	// For equirectangular 2:1, cube faces are roughly Width / 4
 	faceSize := src.Bounds().Dx() / 4
-	faces := []string{"posx", "negx", "posy", "negy", "posz", "negz"}
 	var paths []string
 
 	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
 		os.MkdirAll(outputDir, 0755)
 	}
 
-	for i, name := range faces {
-		faceImg := ExtractFace(src, i, faceSize)
-		path := filepath.Join(outputDir, name+".jpg")
-		err := imaging.Save(faceImg, path)
+	for i, name := range FaceNames {
+		faceImg := ExtractFaceRotated(src, i, faceSize, rot)
+		switch name {
+		case "negy":
+			faceImg = ApplyNadir(faceImg, opts.Branding)
+		case "posx", "negx", "posz", "negz":
+			// Side faces only, a mark on the sky or the nadir patch would look out of place
+			faceImg = ApplyWatermark(faceImg, opts.Branding)
+		}
+		faceVariants, err := SaveVariants(faceImg, filepath.Join(outputDir, name), opts.FaceProfiles)
 		if err != nil {
 			return nil, err
 		}
+		path := faceVariants[0].Path
+		variants[path] = faceVariants
 		paths = append(paths, path)
 	}
-
-	// Generate Thumbnail from Front Face (posz.jpg)
-	frontFacePath := filepath.Join(outputDir, "posz.jpg")
-	frontImg, err := imaging.Open(frontFacePath)
-	if err == nil {
-		uploadPath := filepath.Dir(outputDir)
-		thumb := imaging.Fill(frontImg, 512, 512, imaging.Center, imaging.Lanczos)
-		imaging.Save(thumb, filepath.Join(uploadPath, "thumbnail.jpg"))
-	}
-
 	return paths, nil
 }
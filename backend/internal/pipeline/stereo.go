@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	StereoAuto       = "auto"
+	StereoMono       = "mono"
+	StereoTopBottom  = "top-bottom"   // over/under, left eye on top
+	StereoSideBySide = "side-by-side" // left eye on the left
+)
+
+// IsStereoMode reports whether mode is one of the accepted stereo settings
+func IsStereoMode(mode string) bool {
+	switch mode {
+	case "", StereoAuto, StereoMono, StereoTopBottom, StereoSideBySide:
+		return true
+	}
+	return false
+}
+
+// DetectStereoMode guesses the stereo layout from the image aspect ratio.
+// A mono equirect is 2:1, over/under stacks two of them into 1:1 and
+// side-by-side puts them next to each other at 4:1.
+func DetectStereoMode(b image.Rectangle) string {
+	if b.Dy() == 0 {
+		return StereoMono
+	}
+	ratio := float64(b.Dx()) / float64(b.Dy())
+	switch {
+	case math.Abs(ratio-1) < 0.05:
+		return StereoTopBottom
+	case math.Abs(ratio-4) < 0.2:
+		return StereoSideBySide
+	}
+	return StereoMono
+}
+
+// ResolveStereo returns the stereo layout to slice with. Auto-detection only has the
+// aspect ratio to go on, which partial and cylindrical panoramas share with stereo
+// ones (a 360×90° strip is 4:1), so it only applies to full equirects without crop
+// tags or an explicit field of view. opts.Projection must already be resolved.
+func ResolveStereo(b image.Rectangle, opts Options, gp *GPano) string {
+	if opts.Stereo != "" && opts.Stereo != StereoAuto {
+		return opts.Stereo
+	}
+	switch {
+	case opts.Projection != ProjectionEquirect && opts.Projection != ProjectionDualFisheye:
+		return StereoMono
+	case opts.HFov > 0 || opts.VFov > 0:
+		return StereoMono
+	case gp != nil && gp.HasCrop() && (gp.CroppedAreaImageWidthPixels < gp.FullPanoWidthPixels || gp.CroppedAreaImageHeightPixels < gp.FullPanoHeightPixels):
+		return StereoMono
+	}
+	return DetectStereoMode(b)
+}
+
+// SplitStereo returns the left and right eye equirects. Right is nil for mono input.
+func SplitStereo(src image.Image, mode string) (image.Image, image.Image, error) {
+	b := src.Bounds()
+	switch mode {
+	case StereoMono:
+		return src, nil, nil
+	case StereoTopBottom:
+		half := b.Dy() / 2
+		left := imaging.Crop(src, image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+half))
+		right := imaging.Crop(src, image.Rect(b.Min.X, b.Min.Y+half, b.Max.X, b.Min.Y+2*half))
+		return left, right, nil
+	case StereoSideBySide:
+		half := b.Dx() / 2
+		left := imaging.Crop(src, image.Rect(b.Min.X, b.Min.Y, b.Min.X+half, b.Max.Y))
+		right := imaging.Crop(src, image.Rect(b.Min.X+half, b.Min.Y, b.Min.X+2*half, b.Max.Y))
+		return left, right, nil
+	}
+	return nil, nil, fmt.Errorf("unknown stereo mode %q", mode)
+}
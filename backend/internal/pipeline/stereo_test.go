@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"image"
+	"testing"
+)
+
+func TestResolveStereo(t *testing.T) {
+	strip := image.Rect(0, 0, 4000, 1000)
+	square := image.Rect(0, 0, 2000, 2000)
+	crop := &GPano{CroppedAreaImageWidthPixels: 4000, CroppedAreaImageHeightPixels: 1000, FullPanoWidthPixels: 4000, FullPanoHeightPixels: 2000}
+
+	tests := []struct {
+		name string
+		b    image.Rectangle
+		opts Options
+		gp   *GPano
+		want string
+	}{
+		{"4:1 equirect", strip, Options{Projection: ProjectionEquirect}, nil, StereoSideBySide},
+		{"4:1 cylindrical", strip, Options{Projection: ProjectionCylindrical}, nil, StereoMono},
+		{"4:1 with explicit fov", strip, Options{Projection: ProjectionEquirect, HFov: 360, VFov: 90}, nil, StereoMono},
+		{"4:1 with gpano crop", strip, Options{Projection: ProjectionEquirect}, crop, StereoMono},
+		{"1:1 equirect", square, Options{Projection: ProjectionEquirect}, nil, StereoTopBottom},
+		{"1:1 partial", square, Options{Projection: ProjectionEquirect, HFov: 90}, nil, StereoMono},
+		{"explicit stereo wins", strip, Options{Projection: ProjectionCylindrical, Stereo: StereoSideBySide}, nil, StereoSideBySide},
+	}
+	for _, tt := range tests {
+		if got := ResolveStereo(tt.b, tt.opts, tt.gp); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// ThumbnailSize is one rendered thumbnail of a scene
+type ThumbnailSize struct {
+	Name   string
+	Width  int
+	Height int
+	HFov   float64 // horizontal field of view of the rendered view, degrees
+	File   string  // file name without extension, next to the cubemap directory
+}
+
+// ThumbnailSizes are rendered for every scene. "square" keeps the historical
+// thumbnail.jpg name that clients already load.
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "square", Width: 512, Height: 512, HFov: 90, File: "thumbnail"},
+	{Name: "card", Width: 640, Height: 400, HFov: 100, File: "thumbnail_card"},
+	{Name: "social", Width: 1200, Height: 630, HFov: 110, File: "thumbnail_social"},
+}
+
+// RenderView renders a rectilinear w×h view of an equirect looking at view (viewer
+// coordinates, degrees) with the given horizontal field of view. rot is the rotation
+// applied at cube extraction, so the view matches what the viewer shows.
+func RenderView(src image.Image, view YawPitch, hfov float64, w, h int, rot Orientation) *image.NRGBA {
+	// Downsample first so each output pixel reads roughly one source pixel
+	targetW := int(float64(w) * 360 / hfov)
+	if targetW < src.Bounds().Dx() {
+		src = imaging.Resize(src, targetW, targetW/2, imaging.Box)
+	}
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	camera := Orientation{Yaw: view.Yaw, Pitch: -view.Pitch}.matrix()
+	toSource := rot.matrix().mul(camera)
+	f := float64(w) / 2 / math.Tan(hfov/2*math.Pi/180)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx := (float64(x) + 0.5 - float64(w)/2) / f
+			dy := -(float64(y) + 0.5 - float64(h)/2) / f
+			syaw, spitch := angles(toSource.apply(dx, dy, 1))
+			sx := int((syaw + 180) / 360 * float64(sw))
+			sy := int((90 - spitch) / 180 * float64(sh))
+			dst.Set(x, y, src.At(src.Bounds().Min.X+clampInt(sx, 0, sw-1), src.Bounds().Min.Y+clampInt(sy, 0, sh-1)))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Tone mapping operators for HDR input
+const (
+	ToneMapReinhard = "reinhard" // photographic, keyed to the log-average luminance (default)
+	ToneMapACES     = "aces"     // filmic curve with a soft shoulder
+	ToneMapLinear   = "linear"   // exposure only, highlights clip
+)
+
+// IsToneMap reports whether op is a supported tone mapping operator
+func IsToneMap(op string) bool {
+	switch op {
+	case "", ToneMapReinhard, ToneMapACES, ToneMapLinear:
+		return true
+	}
+	return false
+}
+
+// ToneMap converts linear HDR radiance to an sRGB image. exposure shifts the
+// result in stops before the operator's curve is applied.
+func ToneMap(src *HDRImage, op string, exposure float64) *image.NRGBA {
+	scale := math.Pow(2, exposure)
+	if op == "" || op == ToneMapReinhard {
+		// Map the scene's log-average luminance to middle grey
+		scale *= 0.18 / logAverageLuminance(src)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, src.Width, src.Height))
+	for i := 0; i < src.Width*src.Height; i++ {
+		r := float64(src.Pix[i*3]) * scale
+		g := float64(src.Pix[i*3+1]) * scale
+		b := float64(src.Pix[i*3+2]) * scale
+
+		switch op {
+		case ToneMapACES:
+			r, g, b = acesFilm(r), acesFilm(g), acesFilm(b)
+		case ToneMapLinear:
+		default:
+			// Compress luminance, keep the hue
+			if l := luminanceLinear(r, g, b); l > 0 {
+				f := 1 / (1 + l)
+				r, g, b = r*f, g*f, b*f
+			}
+		}
+
+		if math.IsNaN(r + g + b) {
+			r, g, b = 0, 0, 0
+		}
+		dst.Pix[i*4] = uint8(linearToSRGB(r))
+		dst.Pix[i*4+1] = uint8(linearToSRGB(g))
+		dst.Pix[i*4+2] = uint8(linearToSRGB(b))
+		dst.Pix[i*4+3] = 0xff
+	}
+	return dst
+}
+
+// OpenPanorama decodes an input image, tone mapping HDR files with the scene's operator and exposure
+func OpenPanorama(path string, opts Options) (image.Image, error) {
+	if !IsHDRFile(path) {
+		return imaging.Open(path)
+	}
+	hdr, err := DecodeHDRFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ToneMap(hdr, opts.ToneMap, opts.Exposure), nil
+}
+
+func luminanceLinear(r, g, b float64) float64 {
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+func logAverageLuminance(src *HDRImage) float64 {
+	const delta = 1e-4
+	n := src.Width * src.Height
+	if n == 0 {
+		return 0.18
+	}
+	// A sparse sample is plenty for an average and keeps large maps fast
+	step := max(1, n/(256*256))
+	var sum float64
+	count := 0
+	for i := 0; i < n; i += step {
+		l := luminanceLinear(float64(src.Pix[i*3]), float64(src.Pix[i*3+1]), float64(src.Pix[i*3+2]))
+		if math.IsNaN(l) || math.IsInf(l, 0) {
+			continue
+		}
+		sum += math.Log(delta + math.Max(0, l))
+		count++
+	}
+	if count == 0 {
+		return 0.18
+	}
+	return math.Exp(sum / float64(count))
+}
+
+// acesFilm is Krzysztof Narkowicz's fit of the ACES reference tone curve
+func acesFilm(x float64) float64 {
+	const a, b, c, d, e = 2.51, 0.03, 2.43, 0.59, 0.14
+	x = math.Max(0, x)
+	return (x * (a*x + b)) / (x*(c*x+d) + e)
+}
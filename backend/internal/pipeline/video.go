@@ -0,0 +1,187 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VideoExtensions are the upload extensions treated as 360 video rather than stills
+var VideoExtensions = map[string]bool{".mp4": true, ".mov": true, ".m4v": true, ".webm": true, ".mkv": true}
+
+// IsVideoFile reports whether name has a video extension
+func IsVideoFile(name string) bool {
+	return VideoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// VideoInfo is what ffprobe reports about the first video stream
+type VideoInfo struct {
+	Duration  float64 `json:"duration"` // seconds
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Codec     string  `json:"codec"`
+	FrameRate float64 `json:"frame_rate"`
+}
+
+// Rendition is one HLS variant of a video
+type Rendition struct {
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Bitrate  int    `json:"bitrate"`  // video bits per second
+	Playlist string `json:"playlist"` // media playlist path
+}
+
+// hlsLadder lists the rendition widths tried for 2:1 equirect video with their bitrates.
+// Renditions wider than the source are skipped, the smallest is always produced.
+var hlsLadder = []Rendition{
+	{Width: 3840, Bitrate: 20_000_000},
+	{Width: 2560, Bitrate: 10_000_000},
+	{Width: 1920, Bitrate: 6_000_000},
+	{Width: 1280, Bitrate: 3_000_000},
+}
+
+// VideoResult describes everything ProcessVideo wrote to disk
+type VideoResult struct {
+	Info       VideoInfo
+	Poster     string // full resolution equirect frame, ready for SlicePanoWithOptions
+	Playlist   string // HLS master playlist
+	Renditions []Rendition
+}
+
+// ProcessVideo probes videoPath, writes a poster frame to posterPath and an adaptive
+// HLS rendition below hlsDir. It needs ffmpeg and ffprobe (FFMPEG_PATH / FFPROBE_PATH).
+// Container metadata such as GPS is not carried over to the renditions.
+func ProcessVideo(videoPath, posterPath, hlsDir string) (*VideoResult, error) {
+	info, err := ProbeVideo(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// A frame one second in skips the black fade-in many cameras record
+	at := 1.0
+	if info.Duration < 2 {
+		at = info.Duration / 2
+	}
+	if err := runTool("FFMPEG_PATH", "ffmpeg", "-y", "-v", "error", "-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", videoPath, "-frames:v", "1", "-q:v", "2", "-map_metadata", "-1", posterPath); err != nil {
+		return nil, err
+	}
+
+	renditions, err := transcodeHLS(videoPath, hlsDir, info)
+	if err != nil {
+		return nil, err
+	}
+	master := filepath.Join(hlsDir, "master.m3u8")
+	if err := writeMasterPlaylist(master, renditions); err != nil {
+		return nil, err
+	}
+
+	return &VideoResult{Info: *info, Poster: posterPath, Playlist: master, Renditions: renditions}, nil
+}
+
+// ProbeVideo reads the duration, size and codec of the first video stream
+func ProbeVideo(path string) (*VideoInfo, error) {
+	bin := encoderPath("FFPROBE_PATH", "ffprobe")
+	if bin == "" {
+		return nil, fmt.Errorf("ffprobe not found")
+	}
+	out, err := exec.Command(bin, "-v", "error", "-select_streams", "v:0", "-print_format", "json",
+		"-show_entries", "stream=width,height,codec_name,avg_frame_rate:format=duration", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe: %v", err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width        int    `json:"width"`
+			Height       int    `json:"height"`
+			CodecName    string `json:"codec_name"`
+			AvgFrameRate string `json:"avg_frame_rate"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("ffprobe: %v", err)
+	}
+	if len(probe.Streams) == 0 {
+		return nil, fmt.Errorf("no video stream found")
+	}
+
+	s := probe.Streams[0]
+	info := &VideoInfo{Width: s.Width, Height: s.Height, Codec: s.CodecName}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	if num, den, ok := strings.Cut(s.AvgFrameRate, "/"); ok {
+		n, _ := strconv.ParseFloat(num, 64)
+		d, _ := strconv.ParseFloat(den, 64)
+		if d > 0 {
+			info.FrameRate = n / d
+		}
+	}
+	if info.Width == 0 || info.Height == 0 {
+		return nil, fmt.Errorf("video stream has no dimensions")
+	}
+	return info, nil
+}
+
+// transcodeHLS encodes one H.264 media playlist per rendition that fits the source
+func transcodeHLS(videoPath, hlsDir string, info *VideoInfo) ([]Rendition, error) {
+	if err := os.MkdirAll(hlsDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var renditions []Rendition
+	for i, r := range hlsLadder {
+		if r.Width > info.Width {
+			if i < len(hlsLadder)-1 {
+				continue
+			}
+			r.Width = info.Width / 2 * 2
+		}
+		// Keep the source aspect so stereo layouts survive, with even dimensions for H.264
+		r.Height = int(float64(r.Width)*float64(info.Height)/float64(info.Width)) / 2 * 2
+		name := fmt.Sprintf("%dp", r.Height)
+		r.Playlist = filepath.Join(hlsDir, name+".m3u8")
+
+		rate := strconv.Itoa(r.Bitrate)
+		err := runTool("FFMPEG_PATH", "ffmpeg", "-y", "-v", "error", "-i", videoPath,
+			"-map", "0:v:0", "-map", "0:a:0?", "-map_metadata", "-1",
+			"-vf", fmt.Sprintf("scale=%d:%d", r.Width, r.Height),
+			"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "high",
+			"-b:v", rate, "-maxrate", rate, "-bufsize", strconv.Itoa(r.Bitrate*2),
+			"-c:a", "aac", "-b:a", "128k",
+			"-hls_time", "4", "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(hlsDir, name+"_%04d.ts"),
+			r.Playlist)
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, r)
+	}
+	return renditions, nil
+}
+
+func writeMasterPlaylist(path string, renditions []Rendition) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range renditions {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n%s\n", r.Bitrate+128_000, r.Width, r.Height, filepath.Base(r.Playlist))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func runTool(envVar, name string, args ...string) error {
+	bin := encoderPath(envVar, name)
+	if bin == "" {
+		return fmt.Errorf("%s not found", name)
+	}
+	if out, err := exec.Command(bin, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
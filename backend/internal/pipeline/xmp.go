@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+var xmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+// GPano holds the Google Photo Sphere XMP properties we care about
+type GPano struct {
+	ProjectionType               string
+	CroppedAreaImageWidthPixels  int
+	CroppedAreaImageHeightPixels int
+	FullPanoWidthPixels          int
+	FullPanoHeightPixels         int
+	CroppedAreaLeftPixels        int
+	CroppedAreaTopPixels         int
+	PoseHeadingDegrees           *float64
+	PosePitchDegrees             *float64
+	PoseRollDegrees              *float64
+	InitialViewHeadingDegrees    *float64
+	InitialViewPitchDegrees      *float64
+}
+
+// Validate rejects projections the pipeline cannot slice and inconsistent crop tags
+func (g *GPano) Validate() error {
+	switch g.ProjectionType {
+	case "", ProjectionEquirect, ProjectionCylindrical:
+	default:
+		return fmt.Errorf("unsupported GPano projection %q", g.ProjectionType)
+	}
+	if g.HasCrop() && (g.CroppedAreaLeftPixels+g.CroppedAreaImageWidthPixels > g.FullPanoWidthPixels ||
+		g.CroppedAreaTopPixels+g.CroppedAreaImageHeightPixels > g.FullPanoHeightPixels) {
+		return errors.New("GPano cropped area is larger than the full panorama")
+	}
+	return nil
+}
+
+// HasCrop reports whether the cropped-area tags describe a usable region
+func (g *GPano) HasCrop() bool {
+	return g.FullPanoWidthPixels > 0 && g.FullPanoHeightPixels > 0 &&
+		g.CroppedAreaImageWidthPixels > 0 && g.CroppedAreaImageHeightPixels > 0
+}
+
+// ParseGPano extracts GPano properties from an XMP packet. Both the attribute
+// form (GPano:Foo="1") and the element form (<GPano:Foo>1</GPano:Foo>) are accepted.
+func ParseGPano(packet string) *GPano {
+	props := xmpProperties(packet, "GPano")
+	if len(props) == 0 {
+		return nil
+	}
+	atoi := func(k string) int {
+		n, _ := strconv.Atoi(props[k])
+		return n
+	}
+	float := func(k string) *float64 {
+		f, err := strconv.ParseFloat(props[k], 64)
+		if err != nil {
+			return nil
+		}
+		return &f
+	}
+	return &GPano{
+		ProjectionType:               props["ProjectionType"],
+		CroppedAreaImageWidthPixels:  atoi("CroppedAreaImageWidthPixels"),
+		CroppedAreaImageHeightPixels: atoi("CroppedAreaImageHeightPixels"),
+		FullPanoWidthPixels:          atoi("FullPanoWidthPixels"),
+		FullPanoHeightPixels:         atoi("FullPanoHeightPixels"),
+		CroppedAreaLeftPixels:        atoi("CroppedAreaLeftPixels"),
+		CroppedAreaTopPixels:         atoi("CroppedAreaTopPixels"),
+		PoseHeadingDegrees:           float("PoseHeadingDegrees"),
+		PosePitchDegrees:             float("PosePitchDegrees"),
+		PoseRollDegrees:              float("PoseRollDegrees"),
+		InitialViewHeadingDegrees:    float("InitialViewHeadingDegrees"),
+		InitialViewPitchDegrees:      float("InitialViewPitchDegrees"),
+	}
+}
+
+func xmpProperties(packet, ns string) map[string]string {
+	res := []*regexp.Regexp{
+		regexp.MustCompile(ns + `:(\w+)\s*=\s*"([^"]*)"`),
+		regexp.MustCompile(`<` + ns + `:(\w+)>([^<]*)</` + ns + `:\w+>`),
+	}
+
+	props := map[string]string{}
+	for _, re := range res {
+		for _, m := range re.FindAllStringSubmatch(packet, -1) {
+			props[m[1]] = m[2]
+		}
+	}
+	return props
+}
+
+// walkJPEGSegments calls fn for every marker segment before the image data.
+// Returning false from fn stops the walk.
+func walkJPEGSegments(r io.Reader, fn func(marker byte, data []byte) bool) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return err
+	}
+	if soi[0] != 0xFF || soi[1] != 0xD8 {
+		// Not a JPEG, nothing to read
+		return nil
+	}
+
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+			return err
+		}
+		if hdr[0] != 0xFF {
+			return errors.New("invalid JPEG marker")
+		}
+		marker := hdr[1]
+		if marker == 0xD9 || marker == 0xDA {
+			// End of image or start of scan: no more metadata
+			return nil
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+		if _, err := io.ReadFull(r, hdr[2:]); err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(hdr[2:]))
+		if length < 2 {
+			return errors.New("invalid JPEG segment length")
+		}
+		data := make([]byte, length-2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if !fn(marker, data) {
+			return nil
+		}
+	}
+}
+
+// InitialView returns the GPano initial view as yaw/pitch relative to the image centre
+func (g *GPano) InitialView() (yaw, pitch float64) {
+	if g.InitialViewHeadingDegrees != nil {
+		yaw = *g.InitialViewHeadingDegrees
+		if g.PoseHeadingDegrees != nil {
+			yaw -= *g.PoseHeadingDegrees
+		}
+		yaw = math.Mod(yaw+540, 360) - 180
+	}
+	if g.InitialViewPitchDegrees != nil {
+		pitch = *g.InitialViewPitchDegrees
+	}
+	return yaw, pitch
+}
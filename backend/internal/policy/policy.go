@@ -0,0 +1,96 @@
+package policy
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"a360-platform/backend/internal/models"
+)
+
+// Membership phases (tiered access): members create until ExpiresAt, can still
+// view and share their tours until LocksAt, and are locked out afterwards.
+const (
+	PhaseCreative = "creative"
+	PhaseViewOnly = "view_only"
+	PhaseLocked   = "locked"
+)
+
+// Built-in durations used when no policy is assigned and none is marked default
+const (
+	DefaultCreativeDays = 30
+	DefaultViewOnlyDays = 60
+)
+
+// Phase returns the phase a user is in at the given time; admins are always creative
+func Phase(user *models.User, now time.Time) string {
+	if user.IsAdmin {
+		return PhaseCreative
+	}
+	switch {
+	case now.After(LocksAt(user)):
+		return PhaseLocked
+	case now.After(user.ExpiresAt):
+		return PhaseViewOnly
+	}
+	return PhaseCreative
+}
+
+// LocksAt returns the end of the user's view-only phase
+func LocksAt(user *models.User) time.Time {
+	if user.LocksAt.IsZero() {
+		return user.ExpiresAt.AddDate(0, 0, DefaultViewOnlyDays)
+	}
+	return user.LocksAt
+}
+
+// Resolve returns the policy with the given ID, falling back to the default policy
+// and then to the built-in durations
+func Resolve(db *gorm.DB, id *uint) models.MembershipPolicy {
+	var p models.MembershipPolicy
+	if id != nil && db.First(&p, *id).Error == nil {
+		return p
+	}
+	if db.Where("is_default = ?", true).First(&p).Error == nil {
+		return p
+	}
+	return models.MembershipPolicy{Name: "Standard", CreativeDays: DefaultCreativeDays, ViewOnlyDays: DefaultViewOnlyDays}
+}
+
+// Apply assigns a policy to a user, with the phases counted from start
+func Apply(user *models.User, p models.MembershipPolicy, start time.Time) {
+	user.PolicyID = nil
+	if p.ID != 0 {
+		user.PolicyID = &p.ID
+	}
+	user.ValidFrom = start
+	user.ExpiresAt = start.AddDate(0, 0, p.CreativeDays)
+	user.LocksAt = user.ExpiresAt.AddDate(0, 0, p.ViewOnlyDays)
+}
+
+// Extend adds days to the creative phase, counted from now if it already ended,
+// and keeps the policy's view-only period after it
+func Extend(user *models.User, p models.MembershipPolicy, days int, now time.Time) {
+	start := user.ExpiresAt
+	if start.Before(now) {
+		start = now
+	}
+	user.ExpiresAt = start.AddDate(0, 0, days)
+	if locks := user.ExpiresAt.AddDate(0, 0, p.ViewOnlyDays); locks.After(LocksAt(user)) {
+		user.LocksAt = locks
+	}
+}
+
+// ApplyCohort moves a member onto the cohort's shared dates, where it has them
+func ApplyCohort(user *models.User, cohort models.Cohort) {
+	if cohort.ExpiresAt != nil {
+		user.LocksAt = LocksAt(user).Add(cohort.ExpiresAt.Sub(user.ExpiresAt))
+		user.ExpiresAt = *cohort.ExpiresAt
+	}
+	if cohort.LocksAt != nil {
+		user.LocksAt = *cohort.LocksAt
+	}
+	if user.LocksAt.Before(user.ExpiresAt) {
+		user.LocksAt = user.ExpiresAt
+	}
+}
@@ -75,6 +75,22 @@ func (s *R2Service) UploadFile(ctx context.Context, key string, filePath string,
 	return err
 }
 
+func (s *R2Service) DownloadFile(ctx context.Context, key string, filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	downloader := manager.NewDownloader(s.S3Client)
+	_, err = downloader.Download(ctx, file, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
 func (s *R2Service) DeleteFile(ctx context.Context, key string) error {
 	_, err := s.S3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.Bucket),
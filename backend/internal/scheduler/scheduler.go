@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"a360-platform/backend/internal/mail"
+	"a360-platform/backend/internal/models"
+	"a360-platform/backend/internal/org"
+	"a360-platform/backend/internal/policy"
+)
+
+// Config sets when lifecycle reminders go out, in days before a phase ends
+type Config struct {
+	CreativeOffsets []int // before ExpiresAt, the end of the creative phase
+	ViewOnlyOffsets []int // before LocksAt, the end of the view-only phase
+	Interval        time.Duration
+}
+
+// ConfigFromEnv reads REMINDER_CREATIVE_DAYS and REMINDER_VIEW_ONLY_DAYS (comma
+// separated, e.g. "7,1") and SCHEDULER_INTERVAL (a Go duration, default 1h)
+func ConfigFromEnv() Config {
+	cfg := Config{
+		CreativeOffsets: parseOffsets(os.Getenv("REMINDER_CREATIVE_DAYS"), []int{7, 1}),
+		ViewOnlyOffsets: parseOffsets(os.Getenv("REMINDER_VIEW_ONLY_DAYS"), []int{14, 3}),
+		Interval:        time.Hour,
+	}
+	if d, err := time.ParseDuration(os.Getenv("SCHEDULER_INTERVAL")); err == nil && d > 0 {
+		cfg.Interval = d
+	}
+	return cfg
+}
+
+func parseOffsets(s string, def []int) []int {
+	if strings.TrimSpace(s) == "" {
+		return def
+	}
+	var offsets []int
+	for _, part := range strings.Split(s, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && n > 0 {
+			offsets = append(offsets, n)
+		}
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// Start runs the reminder pass immediately and then every cfg.Interval until ctx is done
+func Start(ctx context.Context, db *gorm.DB, cfg Config) {
+	log.Printf("[SCHEDULER] Reminders at %v days before creative end, %v days before lockout, every %s", cfg.CreativeOffsets, cfg.ViewOnlyOffsets, cfg.Interval)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if n := RunOnce(db, cfg, time.Now()); n > 0 {
+			log.Printf("[SCHEDULER] Sent %d reminders", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends the reminders due at now and returns how many were sent
+func RunOnce(db *gorm.DB, cfg Config, now time.Time) int {
+	sent := 0
+	if len(cfg.CreativeOffsets) > 0 {
+		var users []models.User
+		horizon := now.AddDate(0, 0, cfg.CreativeOffsets[len(cfg.CreativeOffsets)-1])
+		db.Where("is_active = ? AND is_admin = ? AND expires_at > ? AND expires_at <= ?", true, false, now, horizon).Find(&users)
+		for i := range users {
+			if remind(db, &users[i], policy.PhaseCreative, users[i].ExpiresAt, cfg.CreativeOffsets, now) {
+				sent++
+			}
+		}
+	}
+	if len(cfg.ViewOnlyOffsets) > 0 {
+		var users []models.User
+		horizon := now.AddDate(0, 0, cfg.ViewOnlyOffsets[len(cfg.ViewOnlyOffsets)-1])
+		db.Where("is_active = ? AND is_admin = ? AND expires_at <= ? AND locks_at > ? AND locks_at <= ?", true, false, now, now, horizon).Find(&users)
+		for i := range users {
+			if remind(db, &users[i], policy.PhaseViewOnly, users[i].LocksAt, cfg.ViewOnlyOffsets, now) {
+				sent++
+			}
+		}
+	}
+	return sent
+}
+
+// remind sends at most one reminder for the tightest offset window the user is in,
+// so a scheduler that was down does not send a burst of catch-up emails
+func remind(db *gorm.DB, user *models.User, phase string, endsAt time.Time, offsets []int, now time.Time) bool {
+	daysLeft := int(math.Ceil(endsAt.Sub(now).Hours() / 24))
+	offset := 0
+	for _, o := range offsets {
+		if daysLeft <= o {
+			offset = o
+			break
+		}
+	}
+	if offset == 0 {
+		return false
+	}
+
+	// Claim the send first; the unique index makes concurrent schedulers safe
+	reminder := models.Reminder{UserID: user.ID, Phase: phase, OffsetDays: offset, PhaseEndsAt: endsAt, SentAt: now}
+	if res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reminder); res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+
+	if err := mail.SendPhaseReminder(org.Sender(db, user.OrganizationID), user.Email, phase, daysLeft, endsAt); err != nil {
+		log.Printf("[SCHEDULER] Failed to send %s reminder to %s: %v", phase, user.Email, err)
+		db.Delete(&reminder) // retried on the next pass
+		return false
+	}
+
+	// AdminID 0 marks actions taken by the system rather than an admin
+	db.Create(&models.AuditLog{
+		Action:    "Send Reminder",
+		Target:    user.Email,
+		Details:   fmt.Sprintf("%s phase ends %s (%d days left)", phase, endsAt.Format("2006-01-02"), daysLeft),
+		CreatedAt: time.Now(),
+	})
+	return true
+}
@@ -11,6 +11,7 @@ import Projects from './pages/Projects';
 import Viewer from './pages/Viewer';
 import AllProjects from './pages/AllProjects';
 import MagicPortal from './pages/MagicPortal';
+import { canUseConsole } from './lib/utils';
 import { toast } from 'sonner';
 import axios from 'axios';
 
@@ -26,13 +27,13 @@ const ProtectedRoute = ({ children }: GuardProps) => {
 
 const AdminRoute = ({ children }: GuardProps) => {
     const user = JSON.parse(localStorage.getItem('user') || '{}');
-    if (!user.is_admin) return <Navigate to="/projects" replace />;
+    if (!canUseConsole(user)) return <Navigate to="/projects" replace />;
     return <ProtectedRoute>{children}</ProtectedRoute>;
 };
 
 const UserRoute = ({ children }: GuardProps) => {
     const user = JSON.parse(localStorage.getItem('user') || '{}');
-    if (user.is_admin) return <Navigate to="/admin" replace />;
+    if (canUseConsole(user)) return <Navigate to="/admin" replace />;
     return <ProtectedRoute>{children}</ProtectedRoute>;
 };
 
@@ -133,7 +134,7 @@ function App() {
                 <Route path="/magic/:code" element={<MagicRedirect />} />
 
                 <Route path="/" element={
-                    !isAuthenticated ? <Login /> : (user.is_admin ? <Navigate to="/admin" replace /> : <Navigate to="/projects" replace />)
+                    !isAuthenticated ? <Login /> : (canUseConsole(user) ? <Navigate to="/admin" replace /> : <Navigate to="/projects" replace />)
                 } />
             </Routes>
         </Router>
@@ -1,5 +1,6 @@
 import { useState, useEffect } from 'react';
 import axios from 'axios';
+import { logout } from '../lib/session';
 import { Link, useLocation } from 'react-router-dom';
 import {
     Box,
@@ -9,7 +10,7 @@ import {
     X
 } from 'lucide-react';
 import { Button } from '@/components/ui/button';
-import { cn } from '@/lib/utils';
+import { cn, canUseConsole } from '@/lib/utils';
 
 interface LayoutProps {
     children: React.ReactNode;
@@ -23,7 +24,7 @@ export default function Layout({ children }: LayoutProps) {
         const saved = localStorage.getItem('user');
         return saved ? JSON.parse(saved) : null;
     });
-    const isAdmin = !!user?.is_admin;
+    const isAdmin = canUseConsole(user);
     const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
 
     useEffect(() => {
@@ -60,9 +61,8 @@ export default function Layout({ children }: LayoutProps) {
             { id: 'projects', icon: Box, label: 'Personal Projects', href: '/projects' }
         ];
 
-    const handleLogout = () => {
-        localStorage.removeItem('token');
-        localStorage.removeItem('user');
+    const handleLogout = async () => {
+        await logout();
         window.location.href = '/login';
     };
 
@@ -190,7 +190,7 @@ export default function Layout({ children }: LayoutProps) {
                                             {user?.expires_at ? (() => {
                                                 const now = new Date().getTime();
                                                 const creativeExp = new Date(user.expires_at).getTime();
-                                                const totalExp = creativeExp + (60 * 24 * 60 * 60 * 1000); // +60 days
+                                                const totalExp = user.locks_at ? new Date(user.locks_at).getTime() : creativeExp + (60 * 24 * 60 * 60 * 1000);
 
                                                 if (now < creativeExp) {
                                                     const days = Math.max(0, Math.ceil((creativeExp - now) / (1000 * 60 * 60 * 24)));
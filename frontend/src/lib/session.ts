@@ -0,0 +1,63 @@
+import axios from 'axios';
+
+const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
+
+// Access tokens are short lived; on a 401 trade the refresh token for a new pair
+// and replay the request once. Concurrent failures share one refresh call.
+let refreshing: Promise<string> | null = null;
+
+const refresh = async (): Promise<string> => {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) throw new Error('No refresh token');
+    const res = await axios.post(`${API_URL}/api/auth/refresh`, { refresh_token: refreshToken });
+    localStorage.setItem('token', res.data.token);
+    localStorage.setItem('refresh_token', res.data.refresh_token);
+    return res.data.token;
+};
+
+// Pages capture the token when they mount; always send the one in storage so a
+// refresh done by another request in the meantime is picked up
+axios.interceptors.request.use((config) => {
+    const token = localStorage.getItem('token');
+    const auth = config.headers?.Authorization;
+    if (token && typeof auth === 'string' && auth.startsWith('Bearer ') && config.url?.startsWith(API_URL)) {
+        config.headers.Authorization = `Bearer ${token}`;
+    }
+    return config;
+});
+
+// Only the endpoints that establish or end a session are never retried, /auth/me and the rest refresh like any other call
+const noRefresh = ['/api/auth/refresh', '/api/auth/login', '/api/auth/logout'];
+
+axios.interceptors.response.use(
+    (res) => res,
+    async (error) => {
+        const config = error.config;
+        if (error.response?.status !== 401 || !config || config._retried || noRefresh.some((path) => config.url?.includes(path))) {
+            return Promise.reject(error);
+        }
+        try {
+            refreshing = refreshing || refresh().finally(() => { refreshing = null; });
+            const token = await refreshing;
+            config._retried = true;
+            config.headers.Authorization = `Bearer ${token}`;
+            return axios(config);
+        } catch {
+            localStorage.removeItem('refresh_token');
+            return Promise.reject(error);
+        }
+    }
+);
+
+export const logout = async () => {
+    try {
+        await axios.post(`${API_URL}/api/auth/logout`, {}, {
+            headers: { Authorization: `Bearer ${localStorage.getItem('token')}` }
+        });
+    } catch {
+        // Signing out locally still matters when the server is unreachable
+    }
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+    localStorage.removeItem('user');
+};
@@ -5,6 +5,9 @@ export function cn(...inputs: ClassValue[]) {
     return twMerge(clsx(inputs))
 }
 
+// Admins and org-admins both use the admin console, the API scopes what org-admins see
+export const canUseConsole = (user: any) => !!user?.is_admin || user?.role === 'org_admin';
+
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
 const R2_PUBLIC_URL = import.meta.env.VITE_R2_PUBLIC_URL || 'https://pub-2c6a4a0072774a308e398234fc12ea61.r2.dev';
 
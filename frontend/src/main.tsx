@@ -2,6 +2,7 @@ import React from 'react'
 import ReactDOM from 'react-dom/client'
 import App from './App.tsx'
 import './index.css'
+import './lib/session'
 
 ReactDOM.createRoot(document.getElementById('root')!).render(
     <React.StrictMode>
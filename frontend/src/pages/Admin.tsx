@@ -16,7 +16,7 @@ import { toast } from 'sonner';
 import { Badge } from "@/components/ui/badge";
 import { Users, Mail, Trash2, Ticket, Plus, Clock, Pencil, Search, ChevronLeft, ChevronRight, ListTodo, HardDrive, Database, Copy } from 'lucide-react';
 import axios from 'axios';
-import { cn } from '@/lib/utils';
+import { cn, canUseConsole } from '@/lib/utils';
 
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
 
@@ -58,7 +58,7 @@ export default function Admin() {
         const savedUser = localStorage.getItem('user');
         if (savedUser) {
             const user = JSON.parse(savedUser);
-            if (!user.is_admin) {
+            if (!canUseConsole(user)) {
                 window.location.href = '/dashboard';
                 return;
             }
@@ -28,7 +28,7 @@ export default function AllProjects() {
         const token = localStorage.getItem('token');
         if (!token) return;
         try {
-            const res = await axios.get(`${API_URL}/api/projects`, {
+            const res = await axios.get(`${API_URL}/api/projects?scope=all`, {
                 headers: { Authorization: `Bearer ${token}` }
             });
             setProjects(res.data || []);
@@ -4,32 +4,54 @@ import { Label } from "@/components/ui/label"
 import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card"
 import { Link } from "react-router-dom"
 import { useState } from "react"
-import { Presentation, Wand2, KeyRound, User, ArrowRight } from "lucide-react"
+import { Presentation, Wand2, KeyRound, User, ArrowRight, ShieldCheck } from "lucide-react"
 import { AuthBackground } from "@/components/AuthBackground"
 import { toast } from "sonner"
 import axios from "axios"
+import { canUseConsole } from "@/lib/utils"
 
 export default function Login() {
     const [email, setEmail] = useState("")
     const [password, setPassword] = useState("")
     const [loading, setLoading] = useState(false);
+    const [challenge, setChallenge] = useState<string | null>(null);
+    const [code, setCode] = useState("");
+    const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
 
     const handleLogin = async () => {
         setLoading(true);
         try {
-            const res = await axios.post(`${import.meta.env.VITE_API_URL || 'http://localhost:8080'}/api/auth/login`, {
-                email,
-                password
-            });
+            // Second step: authenticator or recovery code for the pending challenge
+            const res = challenge
+                ? await axios.post(`${API_URL}/api/auth/login/2fa`, {
+                    challenge_token: challenge,
+                    ...(code.includes('-') ? { recovery_code: code } : { code })
+                })
+                : await axios.post(`${API_URL}/api/auth/login`, {
+                    email,
+                    password
+                });
+            if (res.data.two_factor_required) {
+                setChallenge(res.data.challenge_token);
+                return;
+            }
+            if (res.data.two_factor_setup_required) {
+                toast.warning("Two-factor authentication is required for admin accounts. Please enable it in your profile.");
+            }
             localStorage.setItem('token', res.data.token);
+            localStorage.setItem('refresh_token', res.data.refresh_token);
             localStorage.setItem('user', JSON.stringify(res.data.user));
 
-            if (res.data.user.is_admin) {
+            if (canUseConsole(res.data.user)) {
                 window.location.href = '/admin';
             } else {
                 window.location.href = '/projects';
             }
         } catch (err: any) {
+            if (err.response?.status === 401 && challenge && err.response?.data?.error?.includes('challenge')) {
+                setChallenge(null);
+                setCode("");
+            }
             toast.error(err.response?.data?.error || "Login failed");
         } finally {
             setLoading(false);
@@ -59,41 +81,61 @@ export default function Login() {
                 </CardHeader>
                 <CardContent className="space-y-4 px-8 pb-8">
 
-                    <div className="space-y-4">
+                    {challenge ? (
                         <div className="space-y-2">
-                            <Label htmlFor="email" className="text-[10px] font-black uppercase tracking-widest text-slate-400 ml-1">Professional Email</Label>
+                            <Label htmlFor="code" className="text-[10px] font-black uppercase tracking-widest text-slate-400 ml-1">Authentication Code</Label>
                             <div className="relative">
-                                <User className="absolute left-4 top-4 h-4 w-4 text-slate-400" />
+                                <ShieldCheck className="absolute left-4 top-4 h-4 w-4 text-slate-400" />
                                 <Input
-                                    id="email"
-                                    type="email"
-                                    placeholder="workshop@a360.co.th"
-                                    className="h-12 pl-12 rounded-2xl bg-slate-50 border-slate-100 focus:bg-white focus:ring-4 focus:ring-blue-100/50 transition-all font-medium"
-                                    value={email}
-                                    onChange={(e) => setEmail(e.target.value)}
+                                    id="code"
+                                    inputMode="numeric"
+                                    autoComplete="one-time-code"
+                                    autoFocus
+                                    placeholder="123456"
+                                    className="h-12 pl-12 rounded-2xl bg-slate-50 border-slate-100 focus:bg-white focus:ring-4 focus:ring-blue-100/50 transition-all font-medium tracking-widest"
+                                    value={code}
+                                    onChange={(e) => setCode(e.target.value)}
                                 />
                             </div>
+                            <p className="text-[10px] text-slate-400 ml-1">Enter the code from your authenticator app, or one of your recovery codes.</p>
                         </div>
-                        <div className="space-y-2">
-                            <div className="flex items-center justify-between">
-                                <Label htmlFor="password" className="text-[10px] font-black uppercase tracking-widest text-slate-400 ml-1">Security Key</Label>
-                                <Link to="/forgot-password" title="Recover Access?" className="text-[10px] text-blue-400 font-bold hover:text-blue-600 transition-colors">
-                                    Recover Key?
-                                </Link>
+                    ) : (
+                        <div className="space-y-4">
+                            <div className="space-y-2">
+                                <Label htmlFor="email" className="text-[10px] font-black uppercase tracking-widest text-slate-400 ml-1">Professional Email</Label>
+                                <div className="relative">
+                                    <User className="absolute left-4 top-4 h-4 w-4 text-slate-400" />
+                                    <Input
+                                        id="email"
+                                        type="email"
+                                        placeholder="workshop@a360.co.th"
+                                        className="h-12 pl-12 rounded-2xl bg-slate-50 border-slate-100 focus:bg-white focus:ring-4 focus:ring-blue-100/50 transition-all font-medium"
+                                        value={email}
+                                        onChange={(e) => setEmail(e.target.value)}
+                                    />
+                                </div>
                             </div>
-                            <div className="relative">
-                                <KeyRound className="absolute left-4 top-4 h-4 w-4 text-slate-400" />
-                                <Input
-                                    id="password"
-                                    type="password"
-                                    placeholder="••••••••"
-                                    className="h-12 pl-12 rounded-2xl bg-slate-50 border-slate-100 focus:bg-white focus:ring-4 focus:ring-blue-100/50 transition-all font-medium"
-                                    value={password}
-                                    onChange={(e) => setPassword(e.target.value)}
-                                />
+                            <div className="space-y-2">
+                                <div className="flex items-center justify-between">
+                                    <Label htmlFor="password" className="text-[10px] font-black uppercase tracking-widest text-slate-400 ml-1">Security Key</Label>
+                                    <Link to="/forgot-password" title="Recover Access?" className="text-[10px] text-blue-400 font-bold hover:text-blue-600 transition-colors">
+                                        Recover Key?
+                                    </Link>
+                                </div>
+                                <div className="relative">
+                                    <KeyRound className="absolute left-4 top-4 h-4 w-4 text-slate-400" />
+                                    <Input
+                                        id="password"
+                                        type="password"
+                                        placeholder="••••••••"
+                                        className="h-12 pl-12 rounded-2xl bg-slate-50 border-slate-100 focus:bg-white focus:ring-4 focus:ring-blue-100/50 transition-all font-medium"
+                                        value={password}
+                                        onChange={(e) => setPassword(e.target.value)}
+                                    />
+                                </div>
                             </div>
                         </div>
-                    </div>
+                    )}
 
                     <Button
                         className="w-full h-14 rounded-2xl bg-blue-500 hover:bg-blue-600 text-white font-black uppercase tracking-widest shadow-xl shadow-blue-200 transition-all hover:scale-[1.02] active:scale-95 mt-6 gap-3 border-b-4 border-blue-700"
@@ -102,7 +144,7 @@ export default function Login() {
                     >
                         {loading ? "Authenticating..." : (
                             <>
-                                Sign In
+                                {challenge ? "Verify" : "Sign In"}
                                 <ArrowRight className="h-5 w-5" />
                             </>
                         )}